@@ -0,0 +1,101 @@
+package bitcask_go
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// mergeWindow 允许自动 merge 的时间段，使用本地时间，结束时间小于开始时间时表示跨越零点
+type mergeWindow struct {
+	start time.Duration // 距离零点的时长
+	end   time.Duration
+}
+
+// parseMergeWindow 解析 "02:00-04:00" 格式的时间段，为空时表示不限制
+func parseMergeWindow(s string) (*mergeWindow, error) {
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid auto merge window %q, want format like 02:00-04:00", s)
+	}
+	var clocks [2]time.Duration
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid auto merge window %q: %w", s, err)
+		}
+		clocks[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	if clocks[0] == clocks[1] {
+		return nil, fmt.Errorf("invalid auto merge window %q, start equals end", s)
+	}
+	return &mergeWindow{start: clocks[0], end: clocks[1]}, nil
+}
+
+// contains 判断时刻 t 是否处于时间段内
+func (w *mergeWindow) contains(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if w.start < w.end {
+		return clock >= w.start && clock < w.end
+	}
+	// 跨越零点，例如 23:00-01:00
+	return clock >= w.start || clock < w.end
+}
+
+// startAutoMerge 启动后台自动 merge 任务，数据库关闭时退出
+func (db *DB) startAutoMerge() {
+	// 参数已经在 checkOptions 中校验过
+	window, _ := parseMergeWindow(db.options.AutoMergeWindow)
+
+	db.bgWait.Add(1)
+	go func() {
+		defer db.bgWait.Done()
+		ticker := time.NewTicker(db.options.AutoMergeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-db.closeCh:
+				return
+			case now := <-ticker.C:
+				if window.contains(now) {
+					db.autoMerge()
+				}
+			}
+		}
+	}()
+}
+
+// autoMerge 可回收的数据达到阈值时执行一次 merge，并记录结果
+// 和 Merge 一样，merge 的结果在下次打开数据库时生效，已有尚未加载的 merge 结果时不再重复执行
+func (db *DB) autoMerge() {
+	db.mu.RLock()
+	reclaimableSize := db.reclaimableSize
+	db.mu.RUnlock()
+	if reclaimableSize == 0 || db.mergePending() {
+		return
+	}
+
+	err := db.Merge()
+	if errors.Is(err, ErrMergeThresholdNotReached) || errors.Is(err, ErrMergeInProgress) {
+		return
+	}
+
+	db.mu.Lock()
+	db.lastAutoMergeTime = time.Now()
+	db.lastAutoMergeErr = err
+	if err == nil {
+		db.autoMergeCount++
+	}
+	db.mu.Unlock()
+
+	if db.options.AutoMergeCallback != nil {
+		db.options.AutoMergeCallback(err)
+	}
+}
@@ -0,0 +1,105 @@
+package bitcask_go
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_parseMergeWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  string
+		wantErr bool
+		in      []string
+		out     []string
+	}{
+		{name: "empty", window: "", in: []string{"00:00", "12:30"}},
+		{name: "same day", window: "02:00-04:00", in: []string{"02:00", "03:59"}, out: []string{"01:59", "04:00", "12:00"}},
+		{name: "cross midnight", window: "23:00-01:00", in: []string{"23:30", "00:30"}, out: []string{"01:00", "22:59"}},
+		{name: "invalid format", window: "02:00", wantErr: true},
+		{name: "invalid clock", window: "02:00-25:00", wantErr: true},
+		{name: "start equals end", window: "02:00-02:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := parseMergeWindow(tt.window)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseMergeWindow() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			for _, clock := range tt.in {
+				c, _ := time.Parse("15:04", clock)
+				if !w.contains(c) {
+					t.Errorf("contains(%s) = false, want true", clock)
+				}
+			}
+			for _, clock := range tt.out {
+				c, _ := time.Parse("15:04", clock)
+				if w.contains(c) {
+					t.Errorf("contains(%s) = true, want false", clock)
+				}
+			}
+		})
+	}
+}
+
+func TestDB_AutoMerge(t *testing.T) {
+	var results = make(chan error, 10)
+	options := defaultOptions()
+	options.AutoMergeInterval = 10 * time.Millisecond
+	options.AutoMergeCallback = func(err error) {
+		results <- err
+	}
+	db, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer func() {
+		// merge 的结果在下次打开时才会生效，需要同时清理 merge 目录
+		_ = os.RemoveAll(db.getMergePath())
+		destroyDB(db)
+	}()
+
+	_ = db.Put([]byte("key"), []byte("value"))
+	_ = db.Put([]byte("key"), []byte("value2"))
+
+	select {
+	case err = <-results:
+		if err != nil {
+			t.Errorf("auto merge error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("auto merge not triggered")
+		return
+	}
+	if stat := db.Stat(); stat.AutoMergeCount != 1 || stat.LastAutoMergeTime.IsZero() || stat.LastAutoMergeError != "" || !stat.MergePending {
+		t.Errorf("Stat() = %+v", stat)
+	}
+
+	// merge 的结果加载之前，即使产生了新的可回收数据也不会重复 merge
+	_ = db.Put([]byte("key"), []byte("value3"))
+	select {
+	case <-results:
+		t.Errorf("auto merge triggered while the previous result is pending")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Close 时后台任务退出
+	if err = db.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	// 重新打开时加载 merge 的结果
+	options.AutoMergeInterval = 0
+	if db, err = Open(options); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if stat := db.Stat(); stat.MergePending {
+		t.Errorf("Stat() after reopen = %+v", stat)
+	}
+	if got, _ := db.Get([]byte("key")); string(got) != "value3" {
+		t.Errorf("Get() after reopen = %s, want value3", got)
+	}
+}
@@ -0,0 +1,293 @@
+package bitcask_go
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/xiecang/bitcask/data"
+	"github.com/xiecang/bitcask/fio"
+	"github.com/xiecang/bitcask/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// BackupManifestFileName 备份目录中记录备份信息的文件
+const BackupManifestFileName = "backup-manifest.json"
+
+// BackupManifest 一次备份的信息，作为下一次增量备份的起点
+type BackupManifest struct {
+	Full        bool             `json:"full"`          // 是否为全量备份，恢复时从最近的全量备份开始
+	MergeFileId uint32           `json:"merge_file_id"` // 备份时已经生效的 merge 结果中第一个未参与 merge 的文件 id，merge 之后需要重新全量备份
+	BaseFileId  uint32           `json:"base_file_id"`  // 本次备份包含的第一个数据文件 id，等于上一次备份的 NextFileId
+	NextFileId  uint32           `json:"next_file_id"`  // 本次备份之后写入的第一个数据文件 id
+	FileIds     []uint32         `json:"file_ids"`      // 本次备份包含的数据文件 id
+	BlobFiles   map[uint32]int64 `json:"blob_files"`    // 备份时所有 blob 文件的大小
+	MaxSeqId    uint64           `json:"max_seq_id"`    // 备份时最大的事务序列号
+}
+
+// ReadBackupManifest 读取备份目录中的备份信息
+func ReadBackupManifest(dir string) (*BackupManifest, error) {
+	buf, err := os.ReadFile(filepath.Join(dir, BackupManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	var manifest BackupManifest
+	if err = json.Unmarshal(buf, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// BackupIncremental 将上一次备份 since 之后新写入或者封存的数据文件备份到目录 dir 中，并写入本次备份的信息
+// since 为零值，或者之后执行过 merge 时进行全量备份，dir 必须不存在或者为空
+func (db *DB) BackupIncremental(dir string, since BackupManifest) (*BackupManifest, error) {
+	if db.options.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	if db.options.IndexType == BPlusTree {
+		return nil, ErrBackupNotSupported
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, ErrDirNotEmpty
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	manifest, files, err := db.sealBackup(since)
+	defer func() {
+		db.mu.Lock()
+		db.checkpoints--
+		db.mu.Unlock()
+	}()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if err = linkOrCopyFile(db.options.DirPath, dir, f); err != nil {
+			return nil, err
+		}
+	}
+	buf, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(dir, BackupManifestFileName), buf, 0644); err != nil {
+		return nil, err
+	}
+	return manifest, syncDir(dir)
+}
+
+// sealBackup 持有写锁封存活跃文件，返回本次备份的信息和需要备份的文件
+func (db *DB) sealBackup(since BackupManifest) (*BackupManifest, []checkpointFile, error) {
+	db.lockForWrite()
+	defer db.mu.Unlock()
+	db.checkpoints++
+
+	if err := db.sealActiveFile(); err != nil {
+		return nil, nil, err
+	}
+	var manifest = &BackupManifest{
+		BlobFiles: make(map[uint32]int64),
+		MaxSeqId:  db.seqId,
+	}
+	if db.activeFile != nil {
+		manifest.NextFileId = db.activeFile.Id
+	}
+	if _, err := os.Stat(data.MergeFinishedFileName(db.options.DirPath)); err == nil {
+		id, err := db.getNonMergeFileId(db.options.DirPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		manifest.MergeFileId = id
+	}
+	// merge 重写了已经备份过的数据文件，需要重新全量备份
+	manifest.Full = since.NextFileId == 0 || since.MergeFileId != manifest.MergeFileId ||
+		since.NextFileId > manifest.NextFileId
+	if !manifest.Full {
+		manifest.BaseFileId = since.NextFileId
+	}
+
+	var files []checkpointFile
+	var add = func(path string, size int64) {
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, checkpointFile{name: filepath.Base(path), size: size})
+		}
+	}
+	for fid := range db.olderFiles {
+		if fid >= manifest.BaseFileId {
+			manifest.FileIds = append(manifest.FileIds, fid)
+		}
+	}
+	sort.Slice(manifest.FileIds, func(i, j int) bool { return manifest.FileIds[i] < manifest.FileIds[j] })
+	for _, fid := range manifest.FileIds {
+		add(data.GetFilePath(db.options.DirPath, fid), -1)
+		add(data.GetDataHintFilePath(db.options.DirPath, fid), -1)
+	}
+	for fid, file := range db.blobFiles {
+		var size = file.WriteOffset
+		manifest.BlobFiles[fid] = size
+		if !manifest.Full && since.BlobFiles[fid] == size {
+			continue
+		}
+		if file != db.activeBlobFile {
+			size = -1
+		}
+		add(data.GetBlobFilePath(db.options.DirPath, fid), size)
+	}
+	if manifest.Full {
+		add(filepath.Join(db.options.DirPath, data.FileNameHint), -1)
+		add(data.MergeFinishedFileName(db.options.DirPath), -1)
+	}
+	return manifest, files, nil
+}
+
+// Restore 依次使用备份目录 backupDirs 中的全量备份和增量备份在目录 targetDir 中重建数据库
+// untilSeqId 大于 0 时只恢复到该事务提交为止，之后写入的数据全部丢弃，包括非事务的写入，options 提供读取加密数据所需的密钥
+// targetDir 必须不存在或者为空
+func Restore(backupDirs []string, targetDir string, untilSeqId uint64, options Options) error {
+	if entries, err := os.ReadDir(targetDir); err == nil && len(entries) > 0 {
+		return ErrDirNotEmpty
+	}
+
+	// 从最近的一次全量备份开始，之后的增量备份必须是连续的
+	var manifests = make([]*BackupManifest, len(backupDirs))
+	var start = -1
+	for i, dir := range backupDirs {
+		manifest, err := ReadBackupManifest(dir)
+		if err != nil {
+			return err
+		}
+		manifests[i] = manifest
+		if manifest.Full {
+			start = i
+		}
+	}
+	if start < 0 {
+		return ErrBackupChainBroken
+	}
+	for i := start + 1; i < len(manifests); i++ {
+		if manifests[i].BaseFileId != manifests[i-1].NextFileId {
+			return ErrBackupChainBroken
+		}
+	}
+
+	if err := os.MkdirAll(targetDir, os.ModePerm); err != nil {
+		return err
+	}
+	for _, dir := range backupDirs[start:] {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Name() == BackupManifestFileName {
+				continue
+			}
+			// 仍在写入的 blob 文件每次备份都会更新，使用最新的一份
+			dst := filepath.Join(targetDir, entry.Name())
+			if err = os.Remove(dst); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err = utils.CopyFile(filepath.Join(dir, entry.Name()), dst, -1); err != nil {
+				return err
+			}
+		}
+	}
+
+	if untilSeqId > 0 && untilSeqId < manifests[len(manifests)-1].MaxSeqId {
+		if err := truncateAfterSeqId(targetDir, untilSeqId, options); err != nil {
+			return err
+		}
+	}
+	return syncDir(targetDir)
+}
+
+// truncateAfterSeqId 删除序列号为 untilSeqId 的事务提交之后写入的所有数据
+// 非事务写入的记录没有序列号，从不大于 untilSeqId 的最后一个事务完成标记之后截断，之后的非事务写入同样被丢弃
+// 数据文件中没有这样的完成标记时（例如已经被 merge），从第一条序列号大于 untilSeqId 的事务记录处截断
+func truncateAfterSeqId(dirPath string, untilSeqId uint64, options Options) error {
+	fileIds, err := dataFileIds(dirPath)
+	if err != nil {
+		return err
+	}
+	var reader = &DB{options: options, encryptor: newEncryptor(options)}
+	var cut *data.LogRecordPos
+	var cutIndex int
+	for i, fid := range fileIds {
+		committed, after, err := reader.findRestorePoint(dirPath, uint32(fid), untilSeqId)
+		if err != nil {
+			return err
+		}
+		if committed >= 0 {
+			cut, cutIndex = &data.LogRecordPos{Fid: uint32(fid), Offset: committed}, i
+		}
+		if after >= 0 {
+			if cut == nil {
+				cut, cutIndex = &data.LogRecordPos{Fid: uint32(fid), Offset: after}, i
+			}
+			break
+		}
+	}
+	if cut == nil {
+		return nil
+	}
+
+	if err = os.Truncate(data.GetFilePath(dirPath, cut.Fid), cut.Offset); err != nil {
+		return err
+	}
+	// 截断之后的 hint 文件不再准确，删除之后启动时扫描数据文件
+	if err = removeIfExists(data.GetDataHintFilePath(dirPath, cut.Fid)); err != nil {
+		return err
+	}
+	for _, later := range fileIds[cutIndex+1:] {
+		if err = removeIfExists(data.GetFilePath(dirPath, uint32(later))); err != nil {
+			return err
+		}
+		if err = removeIfExists(data.GetDataHintFilePath(dirPath, uint32(later))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findRestorePoint 扫描数据文件，返回序列号不大于 seqId 的最后一个事务完成标记之后的位置，
+// 以及第一条序列号大于 seqId 的事务记录的位置，不存在时为 -1
+func (db *DB) findRestorePoint(dirPath string, fileId uint32, seqId uint64) (committed int64, after int64, err error) {
+	file, err := data.OpenFile(dirPath, fileId, fio.FIOStandar)
+	if err != nil {
+		return -1, -1, err
+	}
+	defer func() { _ = file.Close() }()
+
+	committed = -1
+	var offset int64
+	for {
+		record, size, err := file.ReadLogRecord(offset)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return committed, -1, nil
+			}
+			return -1, -1, err
+		}
+		if err = db.decryptRecord(record); err != nil {
+			return -1, -1, err
+		}
+		_, recordSeqId := parsedLogRecordKey(record.Key)
+		if recordSeqId > seqId {
+			return committed, offset, nil
+		}
+		offset += size
+		if recordSeqId != nonTransactionSeqId && record.Type == data.LogRecordTypeTransactionFinished {
+			committed = offset
+		}
+	}
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package bitcask_go
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/xiecang/bitcask/data"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// streamManifestName 备份数据流中第一个文件，记录之后每个文件的大小和校验值
+const streamManifestName = "MANIFEST.json"
+
+// streamManifest 流式备份的校验信息
+type streamManifest struct {
+	CreatedAt time.Time    `json:"created_at"`
+	Files     []streamFile `json:"files"`
+}
+
+type streamFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// streamEntry 写入备份数据流的一个文件，content 不为空时直接写入 content，否则读取数据目录中文件的前 size 字节
+type streamEntry struct {
+	name    string
+	size    int64
+	content []byte
+}
+
+// BackupTo 将数据库的一致性副本以 tar 格式写入 w，第一个文件是记录所有文件大小和 sha256 校验值的清单
+// 与 Checkpoint 相同，只在封存活跃文件时短暂持有写锁，写入过程中不会回收 blob 文件
+func (db *DB) BackupTo(w io.Writer, options BackupStreamOption) error {
+	if db.options.ReadOnly {
+		return ErrReadOnly
+	}
+	if db.options.IndexType == BPlusTree {
+		return ErrBackupNotSupported
+	}
+
+	files, seqId, err := db.sealCheckpoint()
+	defer func() {
+		db.mu.Lock()
+		db.checkpoints--
+		db.mu.Unlock()
+	}()
+	if err != nil {
+		return err
+	}
+
+	// 封存之后的文件不再修改，先计算校验值写入清单，再写入文件内容
+	var entries = []streamEntry{{name: data.FileNameSeqId, size: int64(len(seqId)), content: seqId}}
+	for _, f := range files {
+		var size = f.size
+		if size < 0 {
+			info, err := os.Stat(filepath.Join(db.options.DirPath, f.name))
+			if err != nil {
+				return err
+			}
+			size = info.Size()
+		}
+		entries = append(entries, streamEntry{name: f.name, size: size})
+	}
+	var manifest = streamManifest{CreatedAt: time.Now()}
+	for _, entry := range entries {
+		hash := sha256.New()
+		if err = db.copyStreamEntry(hash, entry); err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, streamFile{
+			Name:   entry.name,
+			Size:   entry.size,
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		})
+	}
+	buf, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	var gw *gzip.Writer
+	if options.Gzip {
+		gw = gzip.NewWriter(w)
+		w = gw
+	}
+	tw := tar.NewWriter(w)
+	if err = writeTarEntry(tw, streamManifestName, manifest.CreatedAt, int64(len(buf)), func(w io.Writer) error {
+		_, err := w.Write(buf)
+		return err
+	}); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		entry := entry
+		if err = writeTarEntry(tw, entry.name, manifest.CreatedAt, entry.size, func(w io.Writer) error {
+			return db.copyStreamEntry(w, entry)
+		}); err != nil {
+			return err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if gw != nil {
+		return gw.Close()
+	}
+	return nil
+}
+
+// copyStreamEntry 将备份文件的内容写入 w
+func (db *DB) copyStreamEntry(w io.Writer, entry streamEntry) error {
+	if entry.content != nil {
+		_, err := w.Write(entry.content)
+		return err
+	}
+	f, err := os.Open(filepath.Join(db.options.DirPath, entry.name))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.CopyN(w, f, entry.size)
+	return err
+}
+
+func writeTarEntry(tw *tar.Writer, name string, modTime time.Time, size int64, write func(w io.Writer) error) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	return write(tw)
+}
+
+// RestoreFrom 从 BackupTo 生成的数据流中恢复数据库到目录 dir，自动识别是否经过 gzip 压缩
+// 先解压到临时目录并校验清单中的每个文件，全部校验通过之后才会创建 dir，dir 必须不存在或者为空
+func RestoreFrom(r io.Reader, dir string) (err error) {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return ErrDirNotEmpty
+	}
+
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = gr.Close() }()
+		r = gr
+	} else {
+		r = br
+	}
+	tr := tar.NewReader(r)
+
+	// 第一个文件必须是清单
+	header, err := tr.Next()
+	if err != nil {
+		return err
+	}
+	if header.Name != streamManifestName {
+		return ErrInvalidBackupStream
+	}
+	var manifest streamManifest
+	if err = json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return err
+	}
+	var expected = make(map[string]streamFile, len(manifest.Files))
+	for _, f := range manifest.Files {
+		if f.Name != filepath.Base(f.Name) || f.Name == streamManifestName {
+			return ErrInvalidBackupStream
+		}
+		expected[f.Name] = f
+	}
+
+	// 在 dir 所在的目录中创建唯一的临时目录，不会和已有的目录冲突，之后可以直接重命名为 dir
+	var parent = filepath.Dir(filepath.Clean(dir))
+	if err = os.MkdirAll(parent, os.ModePerm); err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(parent, filepath.Base(dir)+".restoring-*")
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpDir, 0755); err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(tmpDir)
+		}
+	}()
+
+	for {
+		header, err = tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		f, ok := expected[header.Name]
+		if !ok || header.Size != f.Size {
+			return ErrInvalidBackupStream
+		}
+		delete(expected, header.Name)
+		if err = restoreStreamFile(tr, filepath.Join(tmpDir, f.Name), f); err != nil {
+			return err
+		}
+	}
+	if len(expected) > 0 {
+		// 数据流不完整
+		return ErrInvalidBackupStream
+	}
+	if err = syncDir(tmpDir); err != nil {
+		return err
+	}
+
+	// 全部校验通过之后再放到 dir
+	if err = os.Remove(dir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(tmpDir, dir)
+}
+
+// restoreStreamFile 将数据流中的一个文件写入 path，并检查其大小和校验值
+func restoreStreamFile(r io.Reader, path string, f streamFile) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	if _, err = io.CopyN(io.MultiWriter(file, hash), r, f.Size); err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != f.SHA256 {
+		return ErrInvalidBackupStream
+	}
+	return file.Sync()
+}
@@ -0,0 +1,133 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDB_BackupTo(t *testing.T) {
+	tests := []struct {
+		name    string
+		options BackupStreamOption
+		corrupt func(stream []byte) []byte
+		wantErr error
+	}{
+		{name: "gzip", options: DefaultBackupStreamOptions},
+		{name: "plain tar", options: BackupStreamOption{}},
+		{name: "corrupted content", options: BackupStreamOption{}, corrupt: func(stream []byte) []byte {
+			// 修改数据文件中的一个字节
+			stream[bytes.Index(stream, []byte("value-25"))] ^= 0xff
+			return stream
+		}, wantErr: ErrInvalidBackupStream},
+		{name: "truncated", options: DefaultBackupStreamOptions, corrupt: func(stream []byte) []byte {
+			return stream[:len(stream)/2]
+		}, wantErr: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.MaxFileSize = 512
+			options.LargeValueThreshold = 64
+			var dir = options.DirPath + "-restore"
+			defer func() { _ = os.RemoveAll(dir) }()
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer destroyDB(db)
+
+			var large = bytes.Repeat([]byte("v"), 100)
+			for i := 0; i < 50; i++ {
+				_ = db.Put([]byte(fmt.Sprintf("key-%02d", i)), []byte(fmt.Sprintf("value-%02d", i)))
+			}
+			_ = db.Put([]byte("large"), large)
+			_ = db.Delete([]byte("key-00"))
+
+			var buf bytes.Buffer
+			if err = db.BackupTo(&buf, tt.options); err != nil {
+				t.Errorf("BackupTo() error = %v", err)
+				return
+			}
+			_ = db.Put([]byte("after"), []byte("after"))
+			var stream = buf.Bytes()
+			if tt.corrupt != nil {
+				stream = tt.corrupt(stream)
+			}
+
+			if err = RestoreFrom(bytes.NewReader(stream), dir); !errors.Is(err, tt.wantErr) {
+				t.Errorf("RestoreFrom() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != nil {
+				// 校验失败时不会创建目标目录
+				if _, err = os.Stat(dir); !os.IsNotExist(err) {
+					t.Errorf("RestoreFrom() created %s after a failed validation", dir)
+				}
+				if matches, _ := filepath.Glob(dir + ".restoring-*"); len(matches) > 0 {
+					t.Errorf("RestoreFrom() left the temporary directory %v", matches)
+				}
+				return
+			}
+
+			restoreOptions := options
+			restoreOptions.DirPath = dir
+			restored, err := Open(restoreOptions)
+			if err != nil {
+				t.Errorf("Open() restored error = %v", err)
+				return
+			}
+			defer func() { _ = restored.Close() }()
+			if stat := restored.Stat(); stat.KeyNum != 50 {
+				t.Errorf("restored Stat() = %+v", stat)
+			}
+			if got, _ := restored.Get([]byte("large")); !bytes.Equal(got, large) {
+				t.Errorf("restored Get(large) = %s", got)
+			}
+			if _, err = restored.Get([]byte("after")); err != ErrKeyNotFound {
+				t.Errorf("restored Get(after) error = %v, want %v", err, ErrKeyNotFound)
+			}
+		})
+	}
+}
+
+func TestRestoreFrom_dirNotEmpty(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(dir+"/file", []byte("data"), 0644)
+	if err := RestoreFrom(bytes.NewReader(nil), dir); err != ErrDirNotEmpty {
+		t.Errorf("RestoreFrom() error = %v, want %v", err, ErrDirNotEmpty)
+	}
+}
+
+func TestRestoreFrom_keepsSiblingDir(t *testing.T) {
+	db, err := Open(defaultOptions())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+	_ = db.Put([]byte("key"), []byte("value"))
+	var buf bytes.Buffer
+	if err = db.BackupTo(&buf, DefaultBackupStreamOptions); err != nil {
+		t.Fatalf("BackupTo() error = %v", err)
+	}
+
+	// 与临时目录名称相近的已有目录不受影响
+	var dir = filepath.Join(t.TempDir(), "db")
+	var sibling = filepath.Join(dir+".restoring", "file")
+	_ = os.MkdirAll(filepath.Dir(sibling), os.ModePerm)
+	_ = os.WriteFile(sibling, []byte("data"), 0644)
+	if err = RestoreFrom(&buf, dir); err != nil {
+		t.Fatalf("RestoreFrom() error = %v", err)
+	}
+	if _, err = os.Stat(sibling); err != nil {
+		t.Errorf("RestoreFrom() removed %s: %v", sibling, err)
+	}
+	if matches, _ := filepath.Glob(dir + ".restoring-*"); len(matches) > 0 {
+		t.Errorf("RestoreFrom() left the temporary directory %v", matches)
+	}
+}
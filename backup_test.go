@@ -0,0 +1,165 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeBatches 写入 n 个批次，每个批次写入 batch-<i> 并更新 last，返回最后一个批次的事务序列号
+func writeBatches(t *testing.T, db *DB, from, n int) uint64 {
+	for i := from; i < from+n; i++ {
+		wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+		_ = wb.Put([]byte(fmt.Sprintf("batch-%03d", i)), bytes.Repeat([]byte{byte(i)}, 40))
+		_ = wb.Put([]byte("last"), []byte(fmt.Sprintf("%d", i)))
+		if err := wb.Commit(); err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+		_ = db.Put([]byte(fmt.Sprintf("put-%03d", i)), []byte("value"))
+	}
+	return db.seqId
+}
+
+func TestDB_BackupIncremental(t *testing.T) {
+	options := defaultOptions()
+	options.MaxFileSize = 512
+	options.LargeValueThreshold = 32
+	var root = options.DirPath + "-backup"
+	defer func() { _ = os.RemoveAll(root) }()
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	var dirs []string
+	var manifests []*BackupManifest
+	var seqIds []uint64
+	var backup = func(since BackupManifest) *BackupManifest {
+		dir := filepath.Join(root, fmt.Sprintf("%d", len(dirs)))
+		manifest, err := db.BackupIncremental(dir, since)
+		if err != nil {
+			t.Fatalf("BackupIncremental() error = %v", err)
+		}
+		dirs = append(dirs, dir)
+		manifests = append(manifests, manifest)
+		return manifest
+	}
+	seqIds = append(seqIds, writeBatches(t, db, 0, 10))
+	full := backup(BackupManifest{})
+	seqIds = append(seqIds, writeBatches(t, db, 10, 10))
+	incr := backup(*full)
+	seqIds = append(seqIds, writeBatches(t, db, 20, 10))
+	backup(*incr)
+
+	if !manifests[0].Full || manifests[1].Full || manifests[2].Full {
+		t.Errorf("BackupIncremental() full = %v %v %v", manifests[0].Full, manifests[1].Full, manifests[2].Full)
+	}
+	// 增量备份只包含上一次备份之后的数据文件
+	if len(manifests[1].FileIds) == 0 || manifests[1].FileIds[0] != manifests[0].NextFileId {
+		t.Errorf("BackupIncremental() file ids = %v, previous next file id = %d", manifests[1].FileIds, manifests[0].NextFileId)
+	}
+	if m, err := ReadBackupManifest(dirs[1]); err != nil || m.MaxSeqId != manifests[1].MaxSeqId {
+		t.Errorf("ReadBackupManifest() = %+v, error = %v", m, err)
+	}
+
+	tests := []struct {
+		name       string
+		dirs       []string
+		untilSeqId uint64
+		wantLast   int // 恢复之后最后一个批次的编号
+		wantErr    error
+	}{
+		{name: "full chain", dirs: dirs, wantLast: 29},
+		{name: "full only", dirs: dirs[:1], wantLast: 9},
+		{name: "until second backup", dirs: dirs, untilSeqId: seqIds[1], wantLast: 19},
+		{name: "until middle of chain", dirs: dirs, untilSeqId: seqIds[0] + 5, wantLast: 14},
+		{name: "missing full backup", dirs: dirs[1:], wantErr: ErrBackupChainBroken},
+		{name: "not contiguous", dirs: []string{dirs[0], dirs[2]}, wantErr: ErrBackupChainBroken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := filepath.Join(root, "restore")
+			defer func() { _ = os.RemoveAll(target) }()
+			if err := Restore(tt.dirs, target, tt.untilSeqId, options); err != tt.wantErr {
+				t.Errorf("Restore() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			restoreOptions := options
+			restoreOptions.DirPath = target
+			restored, err := Open(restoreOptions)
+			if err != nil {
+				t.Errorf("Open() restored error = %v", err)
+				return
+			}
+			defer func() { _ = restored.Close() }()
+			if got, _ := restored.Get([]byte("last")); string(got) != fmt.Sprintf("%d", tt.wantLast) {
+				t.Errorf("Get(last) = %s, want %d", got, tt.wantLast)
+			}
+			for i := 0; i < 30; i++ {
+				got, err := restored.Get([]byte(fmt.Sprintf("batch-%03d", i)))
+				if i > tt.wantLast {
+					if err != ErrKeyNotFound {
+						t.Errorf("Get(batch-%03d) error = %v, want %v", i, err, ErrKeyNotFound)
+					}
+					continue
+				}
+				if !bytes.Equal(got, bytes.Repeat([]byte{byte(i)}, 40)) {
+					t.Errorf("Get(batch-%03d) = %v, error = %v", i, got, err)
+				}
+			}
+			// 每个批次之后的非事务写入在该事务提交之后，恢复到该事务时被丢弃
+			var lastPut = tt.wantLast
+			if tt.untilSeqId > 0 {
+				lastPut--
+			}
+			for i := 0; i < 30; i++ {
+				_, err := restored.Get([]byte(fmt.Sprintf("put-%03d", i)))
+				if i > lastPut && err != ErrKeyNotFound {
+					t.Errorf("Get(put-%03d) error = %v, want %v", i, err, ErrKeyNotFound)
+				} else if i <= lastPut && err != nil {
+					t.Errorf("Get(put-%03d) error = %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDB_BackupIncremental_afterMerge(t *testing.T) {
+	options := defaultOptions()
+	options.MaxFileSize = 512
+	var root = options.DirPath + "-backup"
+	defer func() { _ = os.RemoveAll(root) }()
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	writeBatches(t, db, 0, 10)
+	full, err := db.BackupIncremental(filepath.Join(root, "0"), BackupManifest{})
+	if err != nil {
+		t.Fatalf("BackupIncremental() error = %v", err)
+	}
+	writeBatches(t, db, 0, 10)
+	if err = db.Merge(); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	// merge 的结果在重新打开之后生效
+	_ = db.Close()
+	if db, err = Open(options); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	manifest, err := db.BackupIncremental(filepath.Join(root, "1"), *full)
+	if err != nil {
+		t.Fatalf("BackupIncremental() error = %v", err)
+	}
+	if !manifest.Full {
+		t.Errorf("BackupIncremental() after merge should be a full backup")
+	}
+}
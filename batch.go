@@ -16,7 +16,8 @@ type WriteBatch struct {
 	options       WriteBatchOption
 	mu            *sync.Mutex
 	db            *DB
-	pendingWrites map[string]*data.LogRecord // 待写入的数据
+	ns            *Namespace                 // 写入的命名空间，为 nil 时表示默认命名空间
+	pendingWrites map[string]*data.LogRecord // 待写入的数据，由命名空间和 key 共同确定
 }
 
 func (db *DB) NewWriteBatch(options WriteBatchOption) *WriteBatch {
@@ -33,46 +34,75 @@ func (db *DB) NewWriteBatch(options WriteBatchOption) *WriteBatch {
 	}
 }
 
+// In 返回写入命名空间 ns 的批量写，与原批量写共享暂存数据，一次 Commit 可以原子地写入多个命名空间
+func (w *WriteBatch) In(ns *Namespace) *WriteBatch {
+	return &WriteBatch{
+		options:       w.options,
+		mu:            w.mu,
+		db:            w.db,
+		ns:            ns,
+		pendingWrites: w.pendingWrites,
+	}
+}
+
 // Put 添加待批量写入的数据
 func (w *WriteBatch) Put(key, value []byte) error {
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
+	if w.db.options.ReadOnly {
+		return ErrReadOnly
+	}
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	// 暂存待写入的数据
 	record := data.LogRecord{
-		Key:   key,
-		Value: value,
+		Key:       key,
+		Value:     value,
+		Namespace: namespaceName(w.ns),
 	}
-	w.pendingWrites[string(key)] = &record
+	w.pendingWrites[pendingWriteKey(record.Namespace, key)] = &record
 	return nil
 }
 
+// putRecord 暂存一条完整的记录，可以指定命名空间和过期时间，用于导入和修复数据
+func (w *WriteBatch) putRecord(record *data.LogRecord) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pendingWrites[pendingWriteKey(record.Namespace, record.Key)] = record
+}
+
 // Delete 添加待批量删除的数据
 func (w *WriteBatch) Delete(key []byte) error {
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
+	if w.db.options.ReadOnly {
+		return ErrReadOnly
+	}
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	// 数据不存在，直接返回
-	pos := w.db.index.Get(key)
+	pendingKey := pendingWriteKey(namespaceName(w.ns), key)
+	w.db.mu.RLock()
+	pos := w.db.indexOf(w.ns).Get(key)
+	w.db.mu.RUnlock()
 	if pos == nil {
-		if w.pendingWrites[string(key)] != nil {
-			delete(w.pendingWrites, string(key))
+		if w.pendingWrites[pendingKey] != nil {
+			delete(w.pendingWrites, pendingKey)
 		}
 		return nil
 	}
 
 	// 暂存待删除的数据
 	record := data.LogRecord{
-		Key:  key,
-		Type: data.LogRecordTypeDelete,
+		Key:       key,
+		Type:      data.LogRecordTypeDelete,
+		Namespace: namespaceName(w.ns),
 	}
-	w.pendingWrites[string(key)] = &record
+	w.pendingWrites[pendingKey] = &record
 	return nil
 }
 
@@ -90,23 +120,30 @@ func (w *WriteBatch) Commit() error {
 	}
 
 	// 加锁保证事务提交串行化
-	w.db.mu.Lock()
+	w.db.lockForWrite()
 	defer w.db.mu.Unlock()
+	return w.commit()
+}
+
+// commit 写入暂存数据并更新内存索引，调用方需要持有 w.mu 和 db.mu
+func (w *WriteBatch) commit() error {
 	// 获取当前最新的事务序列号
 	seqId := atomic.AddUint64(&w.db.seqId, 1)
 
 	// write
 	var positions = make(map[string]*data.LogRecordPos)
-	for _, record := range w.pendingWrites {
+	for k, record := range w.pendingWrites {
 		pos, err := w.db.appendLogRecord(&data.LogRecord{
-			Key:   logRecordKeyWithSeq(record.Key, seqId),
-			Value: record.Value,
-			Type:  record.Type,
+			Key:       logRecordKeyWithSeq(record.Key, seqId),
+			Value:     record.Value,
+			Type:      record.Type,
+			Expire:    record.Expire,
+			Namespace: record.Namespace,
 		})
 		if err != nil {
 			return err
 		}
-		positions[string(record.Key)] = pos
+		positions[k] = pos
 	}
 
 	// 写入一条标识事务完成的数据
@@ -125,27 +162,43 @@ func (w *WriteBatch) Commit() error {
 
 	// 根据配置决定是否立即刷新数据文件
 	if w.options.SyncWrites && w.db.activeFile != nil {
-		if err := w.db.activeFile.Sync(); err != nil {
+		if err := w.db.syncActiveFile(); err != nil {
 			return err
 		}
 	}
 
 	// 更新内存索引
-	for _, record := range w.pendingWrites {
-		pos := positions[string(record.Key)]
+	var keys = make([][]byte, 0, len(w.pendingWrites))
+	var events = make([]WatchEvent, 0, len(w.pendingWrites))
+	for k, record := range w.pendingWrites {
+		ns := w.db.namespaceOf(record.Namespace)
+		if ns == nil {
+			// 事务只作用于默认命名空间
+			keys = append(keys, record.Key)
+		}
+		pos := positions[k]
 		var oldPos *data.LogRecordPos
 		if record.Type == data.LogRecordTypeDelete {
-			oldPos, _ = w.db.index.Delete(record.Key)
+			oldPos, _ = w.db.indexOf(ns).Delete(record.Key)
+			events = append(events, WatchEvent{Type: WatchEventDelete, Key: record.Key, SeqId: seqId, Namespace: record.Namespace})
 		} else if record.Type == data.LogRecordTypeNormal {
-			oldPos = w.db.index.Put(record.Key, pos)
+			oldPos = w.db.indexOf(ns).Put(record.Key, pos)
+			events = append(events, WatchEvent{Type: WatchEventPut, Key: record.Key, Value: record.Value, SeqId: seqId, Namespace: record.Namespace})
 		}
 		if oldPos != nil {
-			w.db.reclaimableSize += int64(oldPos.Size)
+			w.db.discardPos(ns, oldPos)
 		}
 	}
 
-	// 清空待写入数据
-	w.pendingWrites = make(map[string]*data.LogRecord)
+	w.db.trackWrites(seqId, keys...)
+	w.db.observeIndexSize()
+	// 同一批次的事件一起投递给订阅者
+	w.db.notifyWatchers(events)
+
+	// 清空待写入数据，不能重新分配，通过 In 创建的批量写共享同一份暂存数据
+	for k := range w.pendingWrites {
+		delete(w.pendingWrites, k)
+	}
 	return nil
 }
 
@@ -371,7 +371,7 @@ func TestWriteBatch_Delete(t *testing.T) {
 				t.Errorf("Delete() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			if _, existed := w.pendingWrites[string(tt.args.key)]; existed != tt.wantErr {
+			if _, existed := w.pendingWrites[pendingWriteKey(nil, tt.args.key)]; existed != tt.wantErr {
 				t.Errorf("Delete() key existed, but wantErr %v", tt.wantErr)
 			}
 		})
@@ -431,7 +431,7 @@ func TestWriteBatch_Put(t *testing.T) {
 				t.Errorf("Put() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			if _, existed := w.pendingWrites[string(tt.args.key)]; !existed {
+			if _, existed := w.pendingWrites[pendingWriteKey(nil, tt.args.key)]; !existed {
 				t.Errorf("Put() key not existed")
 			}
 			// 判断是否写入成功
@@ -5,6 +5,10 @@ import (
 	bitcask "github.com/xiecang/bitcask"
 	"github.com/xiecang/bitcask/utils"
 	"golang.org/x/exp/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -72,3 +76,105 @@ func Benchmark_Delete(b *testing.B) {
 	})
 
 }
+
+func Benchmark_PutParallelSync(b *testing.B) {
+	tests := []struct {
+		name               string
+		disableGroupCommit bool
+	}{
+		{name: "PerWriteSync", disableGroupCommit: true},
+		{name: "GroupCommit", disableGroupCommit: false},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			options := bitcask.DefaultOptions
+			options.DirPath = b.TempDir()
+			options.SyncWrites = true
+			options.DisableGroupCommit = tt.disableGroupCommit
+			syncDB, err := bitcask.Open(options)
+			if err != nil {
+				b.Fatalf("Open() error = %v", err)
+			}
+			defer func() { _ = syncDB.Close() }()
+
+			var counter int64
+			b.SetParallelism(16)
+			b.ResetTimer()
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := atomic.AddInt64(&counter, 1)
+					if err := syncDB.Put(utils.GetTestKey(int(i)), utils.RandomValue(128)); err != nil {
+						b.Errorf("Put() error = %v", err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func Benchmark_Open(b *testing.B) {
+	options := bitcask.DefaultOptions
+	options.MaxFileSize = 4 * 1024 * 1024
+	// 有无 hint 文件的情况使用各自的数据目录，删除 hint 文件不会影响之后的用例
+	var noHintDir, hintDir = b.TempDir(), b.TempDir()
+	for _, dir := range []string{noHintDir, hintDir} {
+		options.DirPath = dir
+		loadDB, err := bitcask.Open(options)
+		if err != nil {
+			b.Fatalf("Open() error = %v", err)
+		}
+		for i := 0; i < 200000; i++ {
+			if err = loadDB.Put(utils.GetTestKey(i), utils.RandomValue(128)); err != nil {
+				b.Fatalf("Put() error = %v", err)
+			}
+		}
+		if err = loadDB.Close(); err != nil {
+			b.Fatalf("Close() error = %v", err)
+		}
+	}
+	// 每个数据文件都有对应的 hint 文件
+	if hints, _ := filepath.Glob(filepath.Join(hintDir, "*.hint")); len(hints) < 2 {
+		b.Fatalf("got %d hint files in %s", len(hints), hintDir)
+	}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		withHint    bool
+	}{
+		{name: "Sequential", concurrency: 1},
+		{name: "Concurrent", concurrency: runtime.NumCPU()},
+		{name: "SequentialWithHint", concurrency: 1, withHint: true},
+		{name: "ConcurrentWithHint", concurrency: runtime.NumCPU(), withHint: true},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			options.LoadConcurrency = tt.concurrency
+			options.DirPath = noHintDir
+			if tt.withHint {
+				options.DirPath = hintDir
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if !tt.withHint {
+					// 关闭时会重新生成活跃文件的 hint 文件，每次打开之前都需要删除
+					b.StopTimer()
+					hints, _ := filepath.Glob(filepath.Join(noHintDir, "*.hint"))
+					for _, hint := range hints {
+						_ = os.Remove(hint)
+					}
+					b.StartTimer()
+				}
+				openDB, err := bitcask.Open(options)
+				if err != nil {
+					b.Fatalf("Open() error = %v", err)
+				}
+				b.StopTimer()
+				_ = openDB.Close()
+				b.StartTimer()
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package bitcask_go
+
+import (
+	"github.com/xiecang/bitcask/data"
+	"github.com/xiecang/bitcask/fio"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// blobRef 数据记录在数据文件中的位置，用于查找记录对应的 blob 文件位置
+type blobRef struct {
+	fid    uint32
+	offset int64
+}
+
+func blobRefOf(pos *data.LogRecordPos) blobRef {
+	return blobRef{fid: pos.Fid, offset: pos.Offset}
+}
+
+// shouldStoreInBlob 判断记录的 value 是否需要单独存储到 blob 文件中
+func (db *DB) shouldStoreInBlob(record *data.LogRecord) bool {
+	return db.options.LargeValueThreshold > 0 &&
+		record.Type == data.LogRecordTypeNormal &&
+		!record.ValueInBlob &&
+		len(record.Value) >= db.options.LargeValueThreshold
+}
+
+// writeBlob 将记录的 value 写入活跃 blob 文件，返回 value 在 blob 文件中的位置，需要持有 db.mu
+// blob 文件中的数据和数据文件使用相同的编码，同样会被压缩和加密
+func (db *DB) writeBlob(record *data.LogRecord) (*data.LogRecordPos, error) {
+	blob, err := db.compressRecord(&data.LogRecord{
+		Key:       record.Key,
+		Value:     record.Value,
+		Namespace: record.Namespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if blob, err = db.encryptRecord(blob); err != nil {
+		return nil, err
+	}
+
+	encodedBlob, size := data.EncodeLogRecord(blob)
+	if db.activeBlobFile == nil || db.activeBlobFile.WriteOffset+size > db.options.MaxFileSize {
+		if err = db.setActiveBlobFile(); err != nil {
+			return nil, err
+		}
+	}
+	writeOffset := db.activeBlobFile.WriteOffset
+	if err = db.activeBlobFile.Write(encodedBlob); err != nil {
+		return nil, err
+	}
+	db.bytesWrite += uint(size)
+	db.incrCounter(MetricBytesWritten, uint64(size))
+
+	return &data.LogRecordPos{
+		Fid:    db.activeBlobFile.Id,
+		Offset: writeOffset,
+		Size:   uint32(size),
+	}, nil
+}
+
+// setActiveBlobFile 持久化当前的活跃 blob 文件，并打开新的 blob 文件，需要持有 db.mu
+func (db *DB) setActiveBlobFile() error {
+	var fileId uint32 = 0
+	if db.activeBlobFile != nil {
+		if err := db.activeBlobFile.Sync(); err != nil {
+			return err
+		}
+		fileId = db.activeBlobFile.Id + 1
+	}
+	file, err := data.OpenBlobFile(db.options.DirPath, fileId, fio.FIOStandar)
+	if err != nil {
+		return err
+	}
+	db.blobFiles[fileId] = file
+	db.activeBlobFile = file
+	return nil
+}
+
+// readBlob 读取 blob 文件中 blobPos 位置的 value
+func (db *DB) readBlob(blobPos *data.LogRecordPos) ([]byte, error) {
+	file := db.blobFiles[blobPos.Fid]
+	if file == nil {
+		return nil, ErrFileNotFound
+	}
+	record, _, err := file.ReadLogRecord(blobPos.Offset)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.decryptRecord(record); err != nil {
+		return nil, err
+	}
+	if err = db.decompressRecord(record); err != nil {
+		return nil, err
+	}
+	return record.Value, nil
+}
+
+// discardPos 数据被覆盖或删除之后累加可回收的数据大小，需要持有 db.mu
+// value 存储在 blob 文件中时，同时累加对应 blob 文件的可回收大小
+func (db *DB) discardPos(ns *Namespace, pos *data.LogRecordPos) {
+	db.addReclaimableSize(ns, int64(pos.Size))
+
+	ref := blobRefOf(pos)
+	blobPos, ok := db.blobRefs[ref]
+	if !ok {
+		return
+	}
+	delete(db.blobRefs, ref)
+	if _, ok = db.blobFiles[blobPos.Fid]; ok {
+		db.blobGarbage[blobPos.Fid] += int64(blobPos.Size)
+	}
+}
+
+// loadBlobFiles 打开数据目录下尚未打开的 blob 文件，id 最大的文件作为活跃 blob 文件
+func (db *DB) loadBlobFiles() error {
+	fileIds, err := blobFileIds(db.options.DirPath)
+	if err != nil {
+		return err
+	}
+	for _, fid := range fileIds {
+		var fileId = uint32(fid)
+		if _, ok := db.blobFiles[fileId]; ok {
+			continue
+		}
+		file, err := data.OpenBlobFile(db.options.DirPath, fileId, fio.FIOStandar)
+		if err != nil {
+			return err
+		}
+		if file.WriteOffset, err = file.IOManager.Size(); err != nil {
+			return err
+		}
+		db.blobFiles[fileId] = file
+		if db.activeBlobFile == nil || fileId > db.activeBlobFile.Id {
+			db.activeBlobFile = file
+		}
+	}
+	return nil
+}
+
+// blobFileIds 返回数据目录下所有 blob 文件的 id，从小到大排序
+func blobFileIds(dirPath string) ([]int, error) {
+	dirEntries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	var fileIds []int
+	for _, entry := range dirEntries {
+		if !strings.HasSuffix(entry.Name(), data.BlobFileNameSuffix) {
+			continue
+		}
+		fileId, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), data.BlobFileNameSuffix))
+		if err != nil {
+			return nil, ErrDataDirectoryCorrupted
+		}
+		fileIds = append(fileIds, fileId)
+	}
+	sort.Ints(fileIds)
+	return fileIds, nil
+}
+
+// computeBlobGarbage 加载索引之后，根据仍然有效的 value 计算每个 blob 文件的可回收大小
+func (db *DB) computeBlobGarbage() error {
+	live := make(map[uint32]int64, len(db.blobFiles))
+	for _, blobPos := range db.blobRefs {
+		live[blobPos.Fid] += int64(blobPos.Size)
+	}
+	db.blobGarbage = make(map[uint32]int64, len(db.blobFiles))
+	for fid, file := range db.blobFiles {
+		size, err := file.IOManager.Size()
+		if err != nil {
+			return err
+		}
+		db.blobGarbage[fid] = size - live[fid]
+	}
+	return nil
+}
+
+// blobStat 返回 blob 文件的总大小和可回收的大小，需要持有 db.mu
+func (db *DB) blobStat() (totalSize int64, reclaimableSize int64, err error) {
+	for fid, file := range db.blobFiles {
+		size, err := file.IOManager.Size()
+		if err != nil {
+			return 0, 0, err
+		}
+		totalSize += size
+		reclaimableSize += db.blobGarbage[fid]
+	}
+	return
+}
+
+// BlobGC 回收 blob 文件中的无效数据
+// 可回收数据占比达到 BlobGCThreshold 的 blob 文件，其中仍然有效的 value 会被重写到活跃 blob 文件中，然后删除旧文件
+// 存在尚未释放的快照或者尚未关闭的迭代器时不执行回收，它们可能仍然引用旧文件中的数据
+func (db *DB) BlobGC() error {
+	if db.options.ReadOnly {
+		return ErrReadOnly
+	}
+	db.lockForWrite()
+	defer db.mu.Unlock()
+
+	if len(db.snapshots) > 0 || db.checkpoints > 0 || atomic.LoadInt32(&db.iterators) > 0 {
+		return nil
+	}
+
+	var candidates []uint32
+	for fid, file := range db.blobFiles {
+		if file == db.activeBlobFile {
+			continue
+		}
+		size, err := file.IOManager.Size()
+		if err != nil {
+			return err
+		}
+		if size == 0 || float32(db.blobGarbage[fid])/float32(size) >= db.options.BlobGCThreshold {
+			candidates = append(candidates, fid)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i] < candidates[j] })
+
+	var now = time.Now().UnixNano()
+	for _, fid := range candidates {
+		// 重写仍然有效的 value，并将新的记录写入数据文件
+		for ref, blobPos := range db.blobRefs {
+			if blobPos.Fid != fid {
+				continue
+			}
+			if err := db.rewriteBlob(ref, blobPos, now); err != nil {
+				return err
+			}
+		}
+	}
+	if err := db.syncActiveFile(); err != nil {
+		return err
+	}
+
+	for _, fid := range candidates {
+		file := db.blobFiles[fid]
+		if err := file.Close(); err != nil {
+			return err
+		}
+		if err := os.Remove(data.GetBlobFilePath(db.options.DirPath, fid)); err != nil {
+			return err
+		}
+		delete(db.blobFiles, fid)
+		delete(db.blobGarbage, fid)
+	}
+	return nil
+}
+
+// rewriteBlob 将 ref 位置的记录引用的 value 写入新的位置，并更新内存索引，需要持有 db.mu
+func (db *DB) rewriteBlob(ref blobRef, blobPos *data.LogRecordPos, now int64) error {
+	record, err := db.readLogRecord(&data.LogRecordPos{Fid: ref.fid, Offset: ref.offset})
+	if err != nil {
+		return err
+	}
+	realKey, _ := parsedLogRecordKey(record.Key)
+	ns := db.namespaceOf(record.Namespace)
+	pos := db.indexOf(ns).Get(realKey)
+	if pos == nil || blobRefOf(pos) != ref || pos.IsExpired(now) {
+		// 已经失效的数据不再重写，随旧文件一起删除
+		delete(db.blobRefs, ref)
+		return nil
+	}
+
+	value, err := db.readBlob(blobPos)
+	if err != nil {
+		return err
+	}
+	newPos, err := db.writeLogRecord(&data.LogRecord{
+		Key:       logRecordKeyWithSeq(realKey, nonTransactionSeqId),
+		Value:     value,
+		Type:      data.LogRecordTypeNormal,
+		Expire:    record.Expire,
+		Namespace: record.Namespace,
+	})
+	if err != nil {
+		return err
+	}
+	if oldPos := db.indexOf(ns).Put(realKey, newPos); oldPos != nil {
+		db.discardPos(ns, oldPos)
+	}
+	return nil
+}
+
+// startBlobGC 启动后台 blob 文件回收任务，数据库关闭时退出
+func (db *DB) startBlobGC() {
+	db.bgWait.Add(1)
+	go func() {
+		defer db.bgWait.Done()
+		ticker := time.NewTicker(db.options.BlobGCInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-db.closeCh:
+				return
+			case <-ticker.C:
+				err := db.BlobGC()
+				if db.options.BlobGCCallback != nil {
+					db.options.BlobGCCallback(err)
+				}
+			}
+		}
+	}()
+}
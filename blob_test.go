@@ -0,0 +1,271 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDB_LargeValue(t *testing.T) {
+	var largeValue = bytes.Repeat([]byte("bitcask-large-value"), 100)
+	var smallValue = []byte("small")
+	tests := []struct {
+		name          string
+		compression   CompressionType
+		encryptionKey []byte
+	}{
+		{name: "plain"},
+		{name: "compressed", compression: CompressionFlate},
+		{name: "encrypted", encryptionKey: bytes.Repeat([]byte("k"), 32)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.LargeValueThreshold = 256
+			options.Compression = tt.compression
+			options.EncryptionKey = tt.encryptionKey
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer func() { destroyDB(db) }()
+
+			users, _ := db.Namespace("users")
+			_ = db.Put([]byte("large"), largeValue)
+			_ = db.Put([]byte("small"), smallValue)
+			_ = users.Put([]byte("large"), largeValue)
+
+			// 数据文件中只保留 value 的位置
+			if pos := db.index.Get([]byte("large")); pos.Size >= uint32(len(largeValue)) {
+				t.Errorf("large value record size = %v, want pointer only", pos.Size)
+			}
+			if stat := db.Stat(); stat.BlobFileNum != 1 || stat.BlobSize == 0 {
+				t.Errorf("Stat() = %+v", stat)
+			}
+
+			var check = func(stage string) {
+				if got, _ := db.Get([]byte("large")); !bytes.Equal(got, largeValue) {
+					t.Errorf("%s Get() large value mismatch, len = %v", stage, len(got))
+				}
+				if got, _ := db.Get([]byte("small")); !bytes.Equal(got, smallValue) {
+					t.Errorf("%s Get() = %s, want %s", stage, got, smallValue)
+				}
+				users, _ := db.Namespace("users")
+				if got, _ := users.Get([]byte("large")); !bytes.Equal(got, largeValue) {
+					t.Errorf("%s Namespace.Get() large value mismatch, len = %v", stage, len(got))
+				}
+				iter := db.NewIterator(defaultIteratorOption())
+				for iter.Rewind(); iter.Valid(); iter.Next() {
+					if value, err := iter.Value(); err != nil || len(value) == 0 {
+						t.Errorf("%s Iterator.Value() key = %s, error = %v", stage, iter.Key(), err)
+					}
+				}
+				iter.Close()
+				var folded int
+				_ = db.Fold(func(key, value []byte) bool {
+					if bytes.Equal(key, []byte("large")) && bytes.Equal(value, largeValue) {
+						folded++
+					}
+					return true
+				})
+				if folded != 1 {
+					t.Errorf("%s Fold() large value not found", stage)
+				}
+			}
+			check("open")
+
+			// 重启之后从数据文件中重建 blob 位置
+			_ = db.Put([]byte("small"), []byte("old"))
+			_ = db.Put([]byte("small"), smallValue)
+			if err = db.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			if db, err = Open(options); err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			check("reopen")
+
+			// merge 之后从 hint 文件中重建 blob 位置，blob 文件不会被重写
+			if err = db.Merge(); err != nil {
+				t.Errorf("Merge() error = %v", err)
+			}
+			if err = db.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			if db, err = Open(options); err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			check("merge")
+			if stat := db.Stat(); stat.BlobFileNum != 1 || stat.BlobReclaimableSize != 0 {
+				t.Errorf("Stat() after merge = %+v", stat)
+			}
+		})
+	}
+}
+
+func TestDB_BlobGC(t *testing.T) {
+	options := defaultOptions()
+	options.MaxFileSize = 1024
+	options.LargeValueThreshold = 256
+	db, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer func() { destroyDB(db) }()
+
+	var valueOf = func(key string, version byte) []byte {
+		return bytes.Repeat([]byte(key+string(version)), 200)
+	}
+	var keys = []string{"a", "b", "c", "d", "e"}
+	for _, key := range keys {
+		_ = db.Put([]byte(key), valueOf(key, '1'))
+	}
+	// 覆盖和删除之后旧的 value 成为可回收数据
+	for _, key := range keys[:3] {
+		_ = db.Put([]byte(key), valueOf(key, '2'))
+	}
+	_ = db.Delete([]byte("e"))
+
+	before := db.Stat()
+	if before.BlobFileNum < 2 || before.BlobReclaimableSize == 0 {
+		t.Errorf("Stat() before gc = %+v", before)
+	}
+	if err = db.BlobGC(); err != nil {
+		t.Errorf("BlobGC() error = %v", err)
+	}
+	after := db.Stat()
+	if after.BlobReclaimableSize >= before.BlobReclaimableSize || after.BlobSize >= before.BlobSize {
+		t.Errorf("Stat() after gc = %+v, before = %+v", after, before)
+	}
+
+	var check = func(stage string) {
+		for _, key := range keys[:3] {
+			if got, _ := db.Get([]byte(key)); !bytes.Equal(got, valueOf(key, '2')) {
+				t.Errorf("%s Get(%s) len = %v", stage, key, len(got))
+			}
+		}
+		if got, _ := db.Get([]byte("d")); !bytes.Equal(got, valueOf("d", '1')) {
+			t.Errorf("%s Get(d) len = %v", stage, len(got))
+		}
+		if _, err := db.Get([]byte("e")); err != ErrKeyNotFound {
+			t.Errorf("%s Get(e) error = %v, want %v", stage, err, ErrKeyNotFound)
+		}
+	}
+	check("gc")
+
+	if err = db.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if db, err = Open(options); err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	check("reopen")
+	if stat := db.Stat(); stat.BlobReclaimableSize != after.BlobReclaimableSize {
+		t.Errorf("Stat() after reopen = %+v, want reclaimable %v", stat, after.BlobReclaimableSize)
+	}
+}
+
+func TestDB_BlobGC_snapshot(t *testing.T) {
+	options := defaultOptions()
+	options.MaxFileSize = 1024
+	options.LargeValueThreshold = 256
+	db, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer destroyDB(db)
+
+	var oldValue = bytes.Repeat([]byte("1"), 600)
+	_ = db.Put([]byte("key"), oldValue)
+	snapshot := db.Snapshot()
+	for i := 0; i < 3; i++ {
+		_ = db.Put([]byte("key"), bytes.Repeat([]byte("2"), 600))
+	}
+	// 快照仍然引用旧文件中的数据，不执行回收
+	if err = db.BlobGC(); err != nil {
+		t.Errorf("BlobGC() error = %v", err)
+	}
+	if got, _ := snapshot.Get([]byte("key")); !bytes.Equal(got, oldValue) {
+		t.Errorf("Snapshot.Get() len = %v", len(got))
+	}
+	snapshot.Release()
+	if err = db.BlobGC(); err != nil {
+		t.Errorf("BlobGC() error = %v", err)
+	}
+	if stat := db.Stat(); stat.BlobFileNum != 1 {
+		t.Errorf("Stat() after gc = %+v", stat)
+	}
+}
+
+func TestDB_BlobGC_iterator(t *testing.T) {
+	type valueIterator interface {
+		Rewind()
+		Value() ([]byte, error)
+		Close()
+	}
+	tests := []struct {
+		name     string
+		iterator func(db *DB) valueIterator
+	}{
+		{name: "iterator", iterator: func(db *DB) valueIterator {
+			return db.NewIterator(defaultIteratorOption())
+		}},
+		{name: "txn iterator", iterator: func(db *DB) valueIterator {
+			return db.Begin().NewIterator(defaultIteratorOption())
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.MaxFileSize = 1024
+			options.LargeValueThreshold = 256
+			db, err := Open(options)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer destroyDB(db)
+
+			var oldValue = bytes.Repeat([]byte("1"), 600)
+			_ = db.Put([]byte("key"), oldValue)
+			iterator := tt.iterator(db)
+			for i := 0; i < 3; i++ {
+				_ = db.Put([]byte("key"), bytes.Repeat([]byte("2"), 600))
+			}
+			// 迭代器仍然引用旧文件中的数据，不执行回收
+			if err = db.BlobGC(); err != nil {
+				t.Errorf("BlobGC() error = %v", err)
+			}
+			iterator.Rewind()
+			if got, err := iterator.Value(); !bytes.Equal(got, oldValue) {
+				t.Errorf("Value() len = %v, error = %v", len(got), err)
+			}
+			iterator.Close()
+			iterator.Close()
+			if err = db.BlobGC(); err != nil {
+				t.Errorf("BlobGC() error = %v", err)
+			}
+			if stat := db.Stat(); stat.BlobFileNum != 1 {
+				t.Errorf("Stat() after gc = %+v", stat)
+			}
+		})
+	}
+}
+
+func TestDB_LargeValue_options(t *testing.T) {
+	options := defaultOptions()
+	options.IndexType = BPlusTree
+	options.LargeValueThreshold = 256
+	if _, err := Open(options); err == nil {
+		t.Errorf("Open() with BPlusTree should fail")
+	}
+	options = defaultOptions()
+	options.BlobGCThreshold = 2
+	if _, err := Open(options); err == nil {
+		t.Errorf("Open() with invalid blob gc threshold should fail")
+	}
+}
@@ -0,0 +1,140 @@
+package bitcask_go
+
+import (
+	"github.com/xiecang/bitcask/data"
+	"github.com/xiecang/bitcask/utils"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// linkFile 创建硬链接，测试时替换以模拟跨文件系统
+var linkFile = os.Link
+
+// checkpointFile 检查点中需要包含的文件
+type checkpointFile struct {
+	name string
+	size int64 // 仍在写入的文件只拷贝前 size 字节，小于 0 时表示文件不会再修改，可以直接创建硬链接
+}
+
+// Checkpoint 在目录 dir 中创建数据库的一致性检查点，dir 可以直接作为数据目录打开
+// 只在封存活跃文件时短暂持有写锁，之后对不再修改的数据文件、hint 文件创建硬链接
+// dir 与数据目录不在同一个文件系统时退化为拷贝文件，dir 必须不存在或者为空
+func (db *DB) Checkpoint(dir string) error {
+	if db.options.ReadOnly {
+		return ErrReadOnly
+	}
+	if db.options.IndexType == BPlusTree {
+		// B+ 树索引文件一直在修改，无法通过硬链接得到一致的副本
+		return ErrBackupNotSupported
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return ErrDirNotEmpty
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	files, seqId, err := db.sealCheckpoint()
+	defer func() {
+		db.mu.Lock()
+		db.checkpoints--
+		db.mu.Unlock()
+	}()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err = linkOrCopyFile(db.options.DirPath, dir, f); err != nil {
+			return err
+		}
+	}
+	if err = os.WriteFile(filepath.Join(dir, data.FileNameSeqId), seqId, 0644); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// linkOrCopyFile 将 srcDir 中的文件 f 放入 dstDir，不再修改的文件优先创建硬链接
+func linkOrCopyFile(srcDir, dstDir string, f checkpointFile) error {
+	src, dst := filepath.Join(srcDir, f.name), filepath.Join(dstDir, f.name)
+	if f.size >= 0 {
+		return utils.CopyFile(src, dst, f.size)
+	}
+	if err := linkFile(src, dst); err != nil {
+		// 跨文件系统等无法创建硬链接的情况，拷贝整个文件
+		return utils.CopyFile(src, dst, -1)
+	}
+	return nil
+}
+
+// sealCheckpoint 持有写锁封存当前的活跃文件，返回检查点需要包含的文件，以及序列号文件的内容
+// 返回之后直到调用方减少 db.checkpoints 之前，不会回收任何 blob 文件
+func (db *DB) sealCheckpoint() ([]checkpointFile, []byte, error) {
+	db.lockForWrite()
+	defer db.mu.Unlock()
+	db.checkpoints++
+
+	if err := db.sealActiveFile(); err != nil {
+		return nil, nil, err
+	}
+	record, err := db.seqIdRecord()
+	if err != nil {
+		return nil, nil, err
+	}
+	seqId, _ := data.EncodeLogRecord(record)
+
+	var files []checkpointFile
+	var add = func(path string, size int64) {
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, checkpointFile{name: filepath.Base(path), size: size})
+		}
+	}
+	var fileIds = make([]int, 0, len(db.olderFiles))
+	for fid := range db.olderFiles {
+		fileIds = append(fileIds, int(fid))
+	}
+	sort.Ints(fileIds)
+	for _, fid := range fileIds {
+		add(data.GetFilePath(db.options.DirPath, uint32(fid)), -1)
+		add(data.GetDataHintFilePath(db.options.DirPath, uint32(fid)), -1)
+	}
+	// 检查点打开之后 id 最大的数据文件会成为活跃文件，放入一个空的新文件，避免写入与数据目录共享的硬链接文件
+	if db.activeFile != nil {
+		add(data.GetFilePath(db.options.DirPath, db.activeFile.Id), 0)
+	}
+	for fid, file := range db.blobFiles {
+		var size int64 = -1
+		if file == db.activeBlobFile {
+			size = file.WriteOffset
+		}
+		add(data.GetBlobFilePath(db.options.DirPath, fid), size)
+	}
+	add(filepath.Join(db.options.DirPath, data.FileNameHint), -1)
+	add(data.MergeFinishedFileName(db.options.DirPath), -1)
+	return files, seqId, nil
+}
+
+// sealActiveFile 持久化活跃文件，活跃文件中有数据时切换到新的活跃文件，之前的数据文件不再修改，需要持有 db.mu
+func (db *DB) sealActiveFile() error {
+	if db.activeFile == nil {
+		return nil
+	}
+	if err := db.syncActiveFile(); err != nil {
+		return err
+	}
+	if db.activeFile.WriteOffset == 0 {
+		return nil
+	}
+	return db.rotateActiveFile()
+}
+
+// syncDir 持久化目录中的文件项
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return f.Sync()
+}
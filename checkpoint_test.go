@@ -0,0 +1,226 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xiecang/bitcask/data"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestDB_Checkpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		noLink   bool // 模拟检查点目录与数据目录不在同一个文件系统
+		merge    bool
+		options  func(options *Options)
+		wantLink bool
+	}{
+		{name: "hard link", wantLink: true},
+		{name: "copy fallback", noLink: true},
+		{name: "after merge", merge: true, wantLink: true},
+		{name: "large values", options: func(options *Options) {
+			options.LargeValueThreshold = 64
+		}, wantLink: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.noLink {
+				linkFile = func(oldname, newname string) error {
+					return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: errors.New("cross-device link")}
+				}
+				defer func() { linkFile = os.Link }()
+			}
+			options := defaultOptions()
+			options.MaxFileSize = 512
+			if tt.options != nil {
+				tt.options(&options)
+			}
+			var dir = options.DirPath + "-checkpoint"
+			defer func() { _ = os.RemoveAll(dir) }()
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer destroyDB(db)
+
+			var large = bytes.Repeat([]byte("v"), 100)
+			for i := 0; i < 50; i++ {
+				_ = db.Put([]byte(fmt.Sprintf("key-%02d", i)), []byte(fmt.Sprintf("value-%02d", i)))
+			}
+			_ = db.Put([]byte("large"), large)
+			_ = db.Delete([]byte("key-00"))
+			if tt.merge {
+				if err = db.Merge(); err != nil {
+					t.Errorf("Merge() error = %v", err)
+				}
+			}
+			wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+			_ = wb.Put([]byte("batch"), []byte("batch-value"))
+			_ = wb.Commit()
+
+			if err = db.Checkpoint(dir); err != nil {
+				t.Errorf("Checkpoint() error = %v", err)
+				return
+			}
+			// 检查点之后的写入不影响检查点
+			_ = db.Put([]byte("key-01"), []byte("new-value"))
+			_ = db.Put([]byte("after"), []byte("after"))
+			_ = db.Put([]byte("large-after"), large)
+
+			info, err := os.Stat(data.GetFilePath(dir, 0))
+			if err != nil {
+				t.Errorf("Stat() checkpoint data file error = %v", err)
+				return
+			}
+			srcInfo, _ := os.Stat(data.GetFilePath(options.DirPath, 0))
+			if os.SameFile(info, srcInfo) != tt.wantLink {
+				t.Errorf("checkpoint data file is hard link = %v, want %v", os.SameFile(info, srcInfo), tt.wantLink)
+			}
+
+			checkpointOptions := options
+			checkpointOptions.DirPath = dir
+			cp, err := Open(checkpointOptions)
+			if err != nil {
+				t.Errorf("Open() checkpoint error = %v", err)
+				return
+			}
+			defer func() { _ = cp.Close() }()
+			if stat := cp.Stat(); stat.KeyNum != 51 {
+				t.Errorf("checkpoint Stat() = %+v", stat)
+			}
+			var want = map[string]string{
+				"key-01": "value-01",
+				"key-49": "value-49",
+				"large":  string(large),
+				"batch":  "batch-value",
+			}
+			for key, value := range want {
+				if got, err := cp.Get([]byte(key)); string(got) != value {
+					t.Errorf("checkpoint Get(%s) = %s, error = %v", key, got, err)
+				}
+			}
+			for _, key := range []string{"key-00", "after", "large-after"} {
+				if _, err := cp.Get([]byte(key)); err != ErrKeyNotFound {
+					t.Errorf("checkpoint Get(%s) error = %v, want %v", key, err, ErrKeyNotFound)
+				}
+			}
+			if report, err := cp.Verify(context.Background()); err != nil || !report.OK() {
+				t.Errorf("checkpoint Verify() = %+v, error = %v", report, err)
+			}
+		})
+	}
+}
+
+func TestDB_Checkpoint_concurrentWrites(t *testing.T) {
+	options := defaultOptions()
+	options.MaxFileSize = 4 * 1024
+	var dir = options.DirPath + "-checkpoint"
+	defer func() { _ = os.RemoveAll(dir) }()
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	var wg sync.WaitGroup
+	var stop = make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+			_ = wb.Put([]byte("a"), []byte(fmt.Sprintf("%d", i)))
+			_ = wb.Put([]byte("b"), []byte(fmt.Sprintf("%d", i)))
+			_ = wb.Commit()
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		_ = os.RemoveAll(dir)
+		if err = db.Checkpoint(dir); err != nil {
+			t.Errorf("Checkpoint() error = %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	checkpointOptions := options
+	checkpointOptions.DirPath = dir
+	cp, err := Open(checkpointOptions)
+	if err != nil {
+		t.Fatalf("Open() checkpoint error = %v", err)
+	}
+	defer func() { _ = cp.Close() }()
+	// 批量写入的两个 key 在检查点中保持一致
+	a, _ := cp.Get([]byte("a"))
+	b, _ := cp.Get([]byte("b"))
+	if !bytes.Equal(a, b) {
+		t.Errorf("checkpoint is not consistent, a = %s, b = %s", a, b)
+	}
+}
+
+func TestDB_Checkpoint_dirNotEmpty(t *testing.T) {
+	options := defaultOptions()
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+	dir := t.TempDir()
+	_ = os.WriteFile(dir+"/file", []byte("data"), 0644)
+	if err = db.Checkpoint(dir); err != ErrDirNotEmpty {
+		t.Errorf("Checkpoint() error = %v, want %v", err, ErrDirNotEmpty)
+	}
+}
+
+func TestDB_Checkpoint_writeIsolated(t *testing.T) {
+	options := defaultOptions()
+	options.MaxFileSize = 512
+	var dir = options.DirPath + "-checkpoint"
+	defer func() { _ = os.RemoveAll(dir) }()
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { destroyDB(db) }()
+	for i := 0; i < 20; i++ {
+		_ = db.Put([]byte(fmt.Sprintf("key-%02d", i)), []byte(fmt.Sprintf("value-%02d", i)))
+	}
+	if err = db.Checkpoint(dir); err != nil {
+		t.Fatalf("Checkpoint() error = %v", err)
+	}
+
+	// 检查点中的写入不能进入与数据目录共享的文件
+	checkpointOptions := options
+	checkpointOptions.DirPath = dir
+	cp, err := Open(checkpointOptions)
+	if err != nil {
+		t.Fatalf("Open() checkpoint error = %v", err)
+	}
+	_ = cp.Put([]byte("injected"), []byte("injected"))
+	_ = cp.Put([]byte("key-00"), []byte("overwritten"))
+	_ = cp.Close()
+
+	_ = db.Close()
+	if db, err = Open(options); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, err = db.Get([]byte("injected")); err != ErrKeyNotFound {
+		t.Errorf("Get(injected) error = %v, want %v", err, ErrKeyNotFound)
+	}
+	if value, _ := db.Get([]byte("key-00")); string(value) != "value-00" {
+		t.Errorf("Get(key-00) = %s, want value-00", value)
+	}
+	if got := len(db.ListKeys()); got != 20 {
+		t.Errorf("got %d keys, want 20", got)
+	}
+}
@@ -0,0 +1,136 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"github.com/xiecang/bitcask/data"
+	"io"
+)
+
+type CompressionType = byte
+
+const (
+	CompressionNone  CompressionType = iota // 不压缩
+	CompressionFlate                        // 使用标准库 compress/flate 压缩
+	CompressionGzip                         // 使用标准库 compress/gzip 压缩
+)
+
+// Compressor 压缩算法接口，可以通过 Options.Compressor 使用自定义的实现
+type Compressor interface {
+	// Type 压缩算法标识，写入每条记录中，自定义的实现需要使用大于 CompressionGzip 的值
+	Type() CompressionType
+	// Compress 压缩数据
+	Compress(src []byte) ([]byte, error)
+	// Decompress 解压数据
+	Decompress(src []byte) ([]byte, error)
+}
+
+// flateCompressor 使用 compress/flate 实现的压缩算法
+type flateCompressor struct{}
+
+func (flateCompressor) Type() CompressionType {
+	return CompressionFlate
+}
+
+func (flateCompressor) Compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(src); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (flateCompressor) Decompress(src []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// gzipCompressor 使用 compress/gzip 实现的压缩算法
+type gzipCompressor struct{}
+
+func (gzipCompressor) Type() CompressionType {
+	return CompressionGzip
+}
+
+func (gzipCompressor) Compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// newCompressors 返回可以用于解压的所有压缩算法，以及写入时使用的压缩算法
+func newCompressors(options Options) (map[CompressionType]Compressor, Compressor) {
+	var compressors = map[CompressionType]Compressor{
+		CompressionFlate: flateCompressor{},
+		CompressionGzip:  gzipCompressor{},
+	}
+	if options.Compressor != nil {
+		compressors[options.Compressor.Type()] = options.Compressor
+		return compressors, options.Compressor
+	}
+	return compressors, compressors[options.Compression]
+}
+
+// compressRecord 使用当前的压缩算法压缩记录的 value，返回新的记录
+// value 小于阈值或者压缩之后没有变小时，不压缩
+func (db *DB) compressRecord(record *data.LogRecord) (*data.LogRecord, error) {
+	// 范围删除记录的 value 是范围的上界，启动时需要直接读取，不压缩
+	if db.compressor == nil || record.Codec != CompressionNone || record.ValueInBlob ||
+		record.Type == data.LogRecordTypeRangeDelete ||
+		len(record.Value) == 0 || len(record.Value) < db.options.CompressionThreshold {
+		return record, nil
+	}
+	compressed, err := db.compressor.Compress(record.Value)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressed) >= len(record.Value) {
+		return record, nil
+	}
+	var r = *record
+	r.Value = compressed
+	r.Codec = db.compressor.Type()
+	return &r, nil
+}
+
+// decompressRecord 解压记录的 value
+func (db *DB) decompressRecord(record *data.LogRecord) error {
+	if record.Codec == CompressionNone {
+		return nil
+	}
+	compressor, ok := db.compressors[record.Codec]
+	if !ok {
+		return ErrUnknownCompression
+	}
+	value, err := compressor.Decompress(record.Value)
+	if err != nil {
+		return err
+	}
+	record.Value = value
+	record.Codec = CompressionNone
+	return nil
+}
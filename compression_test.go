@@ -0,0 +1,118 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testCompressor 自定义压缩算法，复用 flate 的实现
+type testCompressor struct {
+	flateCompressor
+}
+
+func (testCompressor) Type() CompressionType {
+	return 10
+}
+
+func TestDB_Compression(t *testing.T) {
+	var largeValue = bytes.Repeat([]byte(`{"name":"bitcask","tags":["kv","log"]}`), 100)
+	var smallValue = []byte(`{"name":"bitcask"}`)
+	tests := []struct {
+		name        string
+		compression CompressionType
+		compressor  Compressor
+		wantCodec   byte
+	}{
+		{name: "none", compression: CompressionNone, wantCodec: CompressionNone},
+		{name: "flate", compression: CompressionFlate, wantCodec: CompressionFlate},
+		{name: "gzip", compression: CompressionGzip, wantCodec: CompressionGzip},
+		{name: "custom", compressor: testCompressor{}, wantCodec: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.Compression = tt.compression
+			options.Compressor = tt.compressor
+			options.CompressionThreshold = 64
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer func() { destroyDB(db) }()
+
+			_ = db.Put([]byte("large"), largeValue)
+			_ = db.Put([]byte("small"), smallValue)
+
+			// 小于阈值的 value 不压缩
+			if pos := db.index.Get([]byte("small")); pos.Size < uint32(len(smallValue)) {
+				t.Errorf("small value size = %v, should not be compressed", pos.Size)
+			}
+			pos := db.index.Get([]byte("large"))
+			if compressed := pos.Size < uint32(len(largeValue)); compressed != (tt.wantCodec != CompressionNone) {
+				t.Errorf("large value size = %v, compressed = %v", pos.Size, compressed)
+			}
+
+			if err = db.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			if db, err = Open(options); err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			if got, _ := db.Get([]byte("large")); !bytes.Equal(got, largeValue) {
+				t.Errorf("Get() large value mismatch")
+			}
+			if got, _ := db.Get([]byte("small")); !bytes.Equal(got, smallValue) {
+				t.Errorf("Get() small value = %s", got)
+			}
+		})
+	}
+}
+
+func TestDB_Compression_mergeRecompress(t *testing.T) {
+	var value = bytes.Repeat([]byte(`{"name":"bitcask"}`), 100)
+	options := defaultOptions()
+	db, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer func() { destroyDB(db) }()
+	_ = db.Put([]byte("old"), value)
+	_ = db.Close()
+
+	// 更换压缩算法之后，新旧记录混合在数据文件中都可以读取
+	options.Compression = CompressionGzip
+	if db, err = Open(options); err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	_ = db.Put([]byte("new"), value)
+	for _, key := range []string{"old", "new"} {
+		if got, _ := db.Get([]byte(key)); !bytes.Equal(got, value) {
+			t.Errorf("Get(%s) value mismatch", key)
+		}
+	}
+	if pos := db.index.Get([]byte("old")); pos.Size < uint32(len(value)) {
+		t.Errorf("old value size = %v, should not be compressed before merge", pos.Size)
+	}
+
+	// merge 之后旧记录按照当前的压缩算法重新压缩
+	_ = db.Put([]byte("garbage"), value)
+	_ = db.Delete([]byte("garbage"))
+	if err = db.Merge(); err != nil {
+		t.Errorf("Merge() error = %v", err)
+	}
+	_ = db.Close()
+	if db, err = Open(options); err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	if pos := db.index.Get([]byte("old")); pos.Size >= uint32(len(value)) {
+		t.Errorf("old value size = %v, should be compressed after merge", pos.Size)
+	}
+	if got, _ := db.Get([]byte("old")); !bytes.Equal(got, value) {
+		t.Errorf("Get() old value mismatch after merge")
+	}
+}
@@ -0,0 +1,114 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"errors"
+	"github.com/xiecang/bitcask/data"
+	"math"
+	"strconv"
+	"time"
+)
+
+// CompareAndSwap 当 key 当前的值等于 oldValue 时写入 newValue，否则返回 ErrValueMismatch
+// oldValue 为 nil 时表示期望 key 不存在
+func (db *DB) CompareAndSwap(key, oldValue, newValue []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+	db.lockForWrite()
+	defer db.mu.Unlock()
+
+	value, _, err := db.getLocked(key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+	if errors.Is(err, ErrKeyNotFound) {
+		if oldValue != nil {
+			return ErrValueMismatch
+		}
+	} else if oldValue == nil || !bytes.Equal(value, oldValue) {
+		return ErrValueMismatch
+	}
+	return db.putLocked(key, newValue, 0)
+}
+
+// PutIfAbsent 当 key 不存在(或已过期)时写入数据，返回是否写入成功
+func (db *DB) PutIfAbsent(key, value []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyIsEmpty
+	}
+	db.lockForWrite()
+	defer db.mu.Unlock()
+
+	if _, _, err := db.getLocked(key); err == nil {
+		return false, nil
+	} else if !errors.Is(err, ErrKeyNotFound) {
+		return false, err
+	}
+	if err := db.putLocked(key, value, 0); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteIfEquals 当 key 当前的值等于 value 时删除 key，否则返回 ErrValueMismatch
+func (db *DB) DeleteIfEquals(key, value []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+	db.lockForWrite()
+	defer db.mu.Unlock()
+
+	current, _, err := db.getLocked(key)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, value) {
+		return ErrValueMismatch
+	}
+	return db.deleteLocked(key)
+}
+
+// IncrBy 将 key 对应的十进制整数值加上 delta，返回相加之后的值
+// key 不存在时视为 0，原有的过期时间保持不变
+func (db *DB) IncrBy(key []byte, delta int64) (int64, error) {
+	if len(key) == 0 {
+		return 0, ErrKeyIsEmpty
+	}
+	db.lockForWrite()
+	defer db.mu.Unlock()
+
+	var current, expire int64
+	value, pos, err := db.getLocked(key)
+	if err == nil {
+		current, err = strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return 0, ErrValueNotInteger
+		}
+		expire = pos.Expire
+	} else if !errors.Is(err, ErrKeyNotFound) {
+		return 0, err
+	}
+
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrIncrOverflow
+	}
+	current += delta
+	if err = db.putLocked(key, []byte(strconv.FormatInt(current, 10)), expire); err != nil {
+		return 0, err
+	}
+	return current, nil
+}
+
+// getLocked 读取 key 对应的数据和索引信息，需要持有 db.mu
+func (db *DB) getLocked(key []byte) ([]byte, *data.LogRecordPos, error) {
+	pos := db.index.Get(key)
+	if pos == nil || pos.IsExpired(time.Now().UnixNano()) {
+		return nil, nil, ErrKeyNotFound
+	}
+	value, err := db.getValueByPosition(pos)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, pos, nil
+}
@@ -0,0 +1,194 @@
+package bitcask_go
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDB_CompareAndSwap(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []byte
+		old      []byte
+		new      []byte
+		wantErr  error
+		want     []byte
+	}{
+		{
+			name:     "swap",
+			existing: []byte("v1"),
+			old:      []byte("v1"),
+			new:      []byte("v2"),
+			want:     []byte("v2"),
+		},
+		{
+			name:     "mismatch",
+			existing: []byte("v1"),
+			old:      []byte("v0"),
+			new:      []byte("v2"),
+			wantErr:  ErrValueMismatch,
+			want:     []byte("v1"),
+		},
+		{
+			name:    "absent expected",
+			old:     nil,
+			new:     []byte("v2"),
+			want:    []byte("v2"),
+			wantErr: nil,
+		},
+		{
+			name:     "absent expected but exists",
+			existing: []byte("v1"),
+			old:      nil,
+			new:      []byte("v2"),
+			wantErr:  ErrValueMismatch,
+			want:     []byte("v1"),
+		},
+		{
+			name:    "not exist",
+			old:     []byte("v1"),
+			new:     []byte("v2"),
+			wantErr: ErrValueMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Open(defaultOptions())
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer destroyDB(db)
+			var key = []byte("key")
+			if tt.existing != nil {
+				_ = db.Put(key, tt.existing)
+			}
+			if err = db.CompareAndSwap(key, tt.old, tt.new); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CompareAndSwap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, _ := db.Get(key)
+			if string(got) != string(tt.want) {
+				t.Errorf("Get() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDB_PutIfAbsent(t *testing.T) {
+	db, err := Open(defaultOptions())
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer destroyDB(db)
+
+	if ok, err := db.PutIfAbsent([]byte("key"), []byte("v1")); !ok || err != nil {
+		t.Errorf("PutIfAbsent() = %v, error = %v, want true", ok, err)
+	}
+	if ok, err := db.PutIfAbsent([]byte("key"), []byte("v2")); ok || err != nil {
+		t.Errorf("PutIfAbsent() = %v, error = %v, want false", ok, err)
+	}
+	if value, _ := db.Get([]byte("key")); string(value) != "v1" {
+		t.Errorf("Get() = %s, want v1", value)
+	}
+
+	// 已过期的 key 视为不存在
+	_ = db.PutWithTTL([]byte("expired"), []byte("v1"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if ok, err := db.PutIfAbsent([]byte("expired"), []byte("v2")); !ok || err != nil {
+		t.Errorf("PutIfAbsent() expired = %v, error = %v, want true", ok, err)
+	}
+}
+
+func TestDB_DeleteIfEquals(t *testing.T) {
+	db, err := Open(defaultOptions())
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer destroyDB(db)
+	_ = db.Put([]byte("key"), []byte("v1"))
+
+	if err = db.DeleteIfEquals([]byte("key"), []byte("v2")); !errors.Is(err, ErrValueMismatch) {
+		t.Errorf("DeleteIfEquals() error = %v, wantErr %v", err, ErrValueMismatch)
+	}
+	if err = db.DeleteIfEquals([]byte("key"), []byte("v1")); err != nil {
+		t.Errorf("DeleteIfEquals() error = %v", err)
+	}
+	if _, err = db.Get([]byte("key")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+	if err = db.DeleteIfEquals([]byte("key"), []byte("v1")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("DeleteIfEquals() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestDB_IncrBy(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []byte
+		delta    int64
+		want     int64
+		wantErr  error
+	}{
+		{name: "not exist", delta: 3, want: 3},
+		{name: "incr", existing: []byte("10"), delta: 5, want: 15},
+		{name: "decr", existing: []byte("10"), delta: -15, want: -5},
+		{name: "not integer", existing: []byte("abc"), delta: 1, wantErr: ErrValueNotInteger},
+		{name: "overflow", existing: []byte(strconv.FormatInt(1<<62, 10)), delta: 1 << 62, wantErr: ErrIncrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Open(defaultOptions())
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer destroyDB(db)
+			if tt.existing != nil {
+				_ = db.Put([]byte("key"), tt.existing)
+			}
+			got, err := db.IncrBy([]byte("key"), tt.delta)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("IncrBy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("IncrBy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("concurrent", func(t *testing.T) {
+		db, err := Open(defaultOptions())
+		if err != nil {
+			t.Errorf("Open() error = %v", err)
+			return
+		}
+		defer destroyDB(db)
+		_ = db.PutWithTTL([]byte("counter"), []byte("0"), time.Hour)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					if _, err := db.IncrBy([]byte("counter"), 1); err != nil {
+						t.Errorf("IncrBy() error = %v", err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if value, _ := db.Get([]byte("counter")); string(value) != "1000" {
+			t.Errorf("Get() = %s, want 1000", value)
+		}
+		if ttl, err := db.TTL([]byte("counter")); err != nil || ttl <= 0 {
+			t.Errorf("TTL() = %v, error = %v, want ttl kept", ttl, err)
+		}
+	})
+}
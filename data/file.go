@@ -11,6 +11,8 @@ import (
 
 const (
 	FileNameSuffix        = ".data"
+	BlobFileNameSuffix    = ".blob"
+	HintFileNameSuffix    = ".hint"
 	FileNameHint          = "hint-index"
 	FileNameMergeFinished = "merge-finished"
 	FileNameSeqId         = "seq-id"
@@ -36,6 +38,12 @@ type logRecordHeader struct {
 	recordType LogRecordType // 标识 LogRecord 的类型
 	keySize    uint32        // key 的长度
 	valueSize  uint32        // value 的长度
+	expire     int64         // 过期时间，0 表示永不过期
+
+	namespaceSize uint32 // 命名空间的长度
+	codec         byte   // value 的压缩算法标识
+	keyId         uint32 // 加密使用的密钥 id
+	valueInBlob   bool   // value 是否存储在 blob 文件中
 }
 
 func (l *logRecordHeader) empty() bool {
@@ -66,6 +74,28 @@ func OpenFile(dirPath string, fileId uint32, ioType fio.FileIOType) (*File, erro
 	return newFile(p, fileId, ioType)
 }
 
+// GetDataHintFilePath 返回数据文件对应的 hint 文件的路径
+func GetDataHintFilePath(dirPath string, fileId uint32) string {
+	name := fmt.Sprintf("%010d%s", fileId, HintFileNameSuffix)
+	return filepath.Join(dirPath, name)
+}
+
+// OpenDataHintFile 打开数据文件对应的 hint 文件
+func OpenDataHintFile(dirPath string, fileId uint32, ioType fio.FileIOType) (*File, error) {
+	return newFile(GetDataHintFilePath(dirPath, fileId), fileId, ioType)
+}
+
+// GetBlobFilePath 返回 blob 文件的路径
+func GetBlobFilePath(dirPath string, fileId uint32) string {
+	name := fmt.Sprintf("%010d%s", fileId, BlobFileNameSuffix)
+	return filepath.Join(dirPath, name)
+}
+
+// OpenBlobFile 打开存储大 value 的 blob 文件
+func OpenBlobFile(dirPath string, fileId uint32, ioType fio.FileIOType) (*File, error) {
+	return newFile(GetBlobFilePath(dirPath, fileId), fileId, ioType)
+}
+
 func GetHintFileName(dirPath string) string {
 	p := filepath.Join(dirPath, FileNameHint)
 	return p
@@ -133,22 +163,34 @@ func (f *File) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 		return nil, 0, io.EOF
 	}
 
-	// 读取 LogRecord 的 key 和 value 的长度
-	keySize, valueSize := int64(header.keySize), int64(header.valueSize)
-	var totalSize = headerSize + keySize + valueSize
+	// 读取 LogRecord 的 namespace、key 和 value 的长度
+	nsSize, keySize, valueSize := int64(header.namespaceSize), int64(header.keySize), int64(header.valueSize)
+	var totalSize = headerSize + nsSize + keySize + valueSize
+	if offset+totalSize > fileSize {
+		// 记录只写入了一部分，例如写入过程中进程崩溃
+		return nil, 0, io.ErrUnexpectedEOF
+	}
 
 	var logRecord = &LogRecord{
-		Type: header.recordType,
+		Type:   header.recordType,
+		Expire: header.expire,
+		Codec:  header.codec,
+		KeyId:  header.keyId,
+
+		ValueInBlob: header.valueInBlob,
 	}
-	// 读取 LogRecord 的 key 和 value
-	if keySize > 0 || valueSize > 0 {
-		kvBuf, err := f.readNBytes(keySize+valueSize, offset+headerSize)
+	// 读取 LogRecord 的 namespace、key 和 value
+	if nsSize > 0 || keySize > 0 || valueSize > 0 {
+		kvBuf, err := f.readNBytes(nsSize+keySize+valueSize, offset+headerSize)
 		if err != nil {
 			return nil, 0, err
 		}
-		// 解析 LogRecord 的 key 和 value
-		logRecord.Key = kvBuf[:keySize]
-		logRecord.Value = kvBuf[keySize:]
+		// 解析 LogRecord 的 namespace、key 和 value
+		if nsSize > 0 {
+			logRecord.Namespace = kvBuf[:nsSize]
+		}
+		logRecord.Key = kvBuf[nsSize : nsSize+keySize]
+		logRecord.Value = kvBuf[nsSize+keySize:]
 	}
 
 	// 计算 crc 校验值
@@ -178,12 +220,37 @@ func (f *File) Close() error {
 
 // WriteHintRecord 写入索引信息到 Hint 索引文件
 func (f *File) WriteHintRecord(key []byte, pos *LogRecordPos) error {
-	var record = &LogRecord{
-		Key:   key,
-		Value: EncodeLogRecordPos(pos),
-		Type:  LogRecordTypeHint,
+	return f.WriteNamespaceHintRecord(nil, key, pos)
+}
+
+// WriteNamespaceHintRecord 写入命名空间中 key 的索引信息到 Hint 索引文件
+func (f *File) WriteNamespaceHintRecord(namespace, key []byte, pos *LogRecordPos) error {
+	return f.WriteLogRecord(NewHintRecord(namespace, key, pos))
+}
+
+// NewHintRecord 构造 Hint 索引文件中的记录
+func NewHintRecord(namespace, key []byte, pos *LogRecordPos) *LogRecord {
+	return &LogRecord{
+		Key:       key,
+		Value:     EncodeLogRecordPos(pos),
+		Type:      LogRecordTypeHint,
+		Namespace: namespace,
 	}
+}
+
+// NewBlobHintRecord 构造 value 存储在 blob 文件中的数据在 Hint 索引文件中的记录
+func NewBlobHintRecord(namespace, key []byte, pos, blobPos *LogRecordPos) *LogRecord {
+	return &LogRecord{
+		Key:         key,
+		Value:       EncodeBlobHint(pos, blobPos),
+		Type:        LogRecordTypeHint,
+		Namespace:   namespace,
+		ValueInBlob: true,
+	}
+}
 
+// WriteLogRecord 编码并写入一条记录
+func (f *File) WriteLogRecord(record *LogRecord) error {
 	encodeRecord, _ := EncodeLogRecord(record)
 	return f.Write(encodeRecord)
 }
@@ -224,7 +291,6 @@ func CleanDBFile(path string) error {
 	//})
 	files, err := os.ReadDir(path)
 	if err != nil {
-		fmt.Printf("Error reading directory %q: %v\n", path, err)
 		return err
 	}
 	for _, file := range files {
@@ -233,7 +299,6 @@ func CleanDBFile(path string) error {
 			if err = os.Remove(p); err != nil {
 				return err
 			}
-			fmt.Printf("Deleted file: %s\n", p)
 		}
 	}
 	return err
@@ -2,7 +2,9 @@ package data
 
 import (
 	"bytes"
+	"errors"
 	"github.com/xiecang/bitcask/fio"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -130,6 +132,75 @@ func TestFile_ReadLogRecord(t *testing.T) {
 			want1:   10,
 			wantErr: false,
 		},
+		{
+			name: "record with namespace and expire",
+			fields: fields{
+				id:      1,
+				dirPath: os.TempDir(),
+			},
+			args: args{
+				offset: 0,
+			},
+			want: &LogRecord{
+				Key:       []byte("key"),
+				Value:     []byte("value"),
+				Expire:    100,
+				Namespace: []byte("ns"),
+			},
+			want1:   20,
+			wantErr: false,
+		},
+		{
+			name: "record with codec",
+			fields: fields{
+				id:      1,
+				dirPath: os.TempDir(),
+			},
+			args: args{
+				offset: 0,
+			},
+			want: &LogRecord{
+				Key:   []byte("key"),
+				Value: []byte("value"),
+				Codec: 1,
+			},
+			want1:   16,
+			wantErr: false,
+		},
+		{
+			name: "encrypted record",
+			fields: fields{
+				id:      1,
+				dirPath: os.TempDir(),
+			},
+			args: args{
+				offset: 0,
+			},
+			want: &LogRecord{
+				Key:   []byte("key"),
+				Value: []byte("value"),
+				KeyId: 300,
+			},
+			want1:   17,
+			wantErr: false,
+		},
+		{
+			name: "value in blob",
+			fields: fields{
+				id:      1,
+				dirPath: os.TempDir(),
+			},
+			args: args{
+				offset: 0,
+			},
+			want: &LogRecord{
+				Key:         []byte("key"),
+				Value:       EncodeLogRecordPos(&LogRecordPos{Fid: 1, Offset: 100, Size: 20}),
+				ValueInBlob: true,
+			},
+			want1:   14,
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -185,6 +256,25 @@ func TestFile_ReadLogRecord(t *testing.T) {
 	}
 }
 
+func TestFile_ReadLogRecord_partial(t *testing.T) {
+	dirPath := os.TempDir()
+	defer func() { _ = CleanDBFile(dirPath) }()
+	f, err := OpenFile(dirPath, 1, fio.FIOStandar)
+	if err != nil {
+		t.Errorf("OpenFile() error = %v", err)
+		return
+	}
+	recordBytes, _ := EncodeLogRecord(&LogRecord{Key: []byte("key"), Value: []byte("value")})
+	// 只写入记录的一部分
+	if err = f.Write(recordBytes[:len(recordBytes)-2]); err != nil {
+		t.Errorf("Write() error = %v", err)
+		return
+	}
+	if _, _, err = f.ReadLogRecord(0); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ReadLogRecord() error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
 func TestFile_Sync(t *testing.T) {
 	type fields struct {
 		dirPath string
@@ -13,18 +13,38 @@ const (
 	LogRecordTypeTransactionFinished
 	LogRecordTypeHint
 	LogRecordTypeSeqId
+	LogRecordTypeNamespaceDrop // 删除整个命名空间
+	LogRecordTypeRangeDelete   // 删除 [key, value) 范围内的所有 key，value 为空时不限制上界
 )
 
-// crc type keySize valueSize key value
-// 4   1    5(max)   5(max)    n    m
-const maxLogRecordHeaderSize = binary.MaxVarintLen32*2 + 1 + 4
+// type 字节的低 3 位表示记录类型，高位作为标志位，标识 header 中是否带有扩展字段
+// 不带标志位的记录和旧版本的编码完全一致
+const (
+	logRecordTypeMask byte = 0x07
+	flagExpire        byte = 0x80 // header 中带有过期时间
+	flagNamespace     byte = 0x40 // header 中带有命名空间的长度，命名空间存储在 key 之前
+	flagCodec         byte = 0x20 // header 中带有 value 的压缩算法标识
+	flagEncrypted     byte = 0x10 // header 中带有加密密钥的 id，key 和 value 均已加密
+	flagBlob          byte = 0x08 // value 存储在 blob 文件中，记录中的 value 为 blob 文件中的位置
+)
+
+// crc type keySize valueSize [expire] [namespaceSize] [codec] [keyId] [namespace] key value
+// 4   1    5(max)   5(max)    10(max)  5(max)          1       5(max)  n           m   k
+const maxLogRecordHeaderSize = binary.MaxVarintLen32*4 + binary.MaxVarintLen64 + 1 + 1 + 4
 
 // LogRecord 写入到数据文件的记录
 // 之所以叫日志，是因为数据是追加写入的，类似日志的格式
 type LogRecord struct {
-	Key   []byte
-	Value []byte
-	Type  LogRecordType
+	Key    []byte
+	Value  []byte
+	Type   LogRecordType
+	Expire int64 // 过期时间，UnixNano，0 表示永不过期
+
+	Namespace []byte // 命名空间，为空表示默认命名空间
+	Codec     byte   // value 的压缩算法标识，0 表示 value 未经压缩
+	KeyId     uint32 // 加密 key 和 value 使用的密钥 id，0 表示未加密
+
+	ValueInBlob bool // value 是否存储在 blob 文件中，为 true 时 Value 为编码后的 blob 文件位置
 }
 
 // LogRecordPos 数据内存索引，描述数据在磁盘上的位置
@@ -32,6 +52,12 @@ type LogRecordPos struct {
 	Fid    uint32 // 文件 id, 表示数据存储在哪个文件中
 	Offset int64  // 文件中的偏移量，表示数据存储在文件中的哪个位置
 	Size   uint32 // 数据在磁盘上的大小
+	Expire int64  // 过期时间，UnixNano，0 表示永不过期
+}
+
+// IsExpired 判断数据在 now (UnixNano) 时刻是否已经过期
+func (p *LogRecordPos) IsExpired(now int64) bool {
+	return p.Expire > 0 && p.Expire <= now
 }
 
 // TransactionRecord 暂存事务记录
@@ -42,29 +68,59 @@ type TransactionRecord struct {
 
 // EncodeLogRecord 对 LogRecord 进行编码，返回编码后的字节数组和字节数组的长度
 //
-//	+-------------+-------------+-------------+--------------+-------------+--------------+
-//	| crc 校验值  |  type 类型   |    key size |   value size |      key    |      value   |
-//	+-------------+-------------+-------------+--------------+-------------+--------------+
-//	    4字节          1字节        变长（最大5）   变长（最大5）     变长           变长
+//	+-----------+-----------+-----------+------------+--------------+------------------+-------------+--------------+----------------+---------+---------+
+//	| crc 校验值 | type 类型  |  key size | value size | expire(可选) | ns size(可选)     | codec(可选)  | key id(可选)  | namespace(可选) |   key   |  value  |
+//	+-----------+-----------+-----------+------------+--------------+------------------+-------------+--------------+----------------+---------+---------+
+//	   4字节        1字节     变长（最大5） 变长（最大5）  变长（最大10）     变长（最大5）        1字节      变长（最大5）        变长           变长      变长
 func EncodeLogRecord(record *LogRecord) ([]byte, int64) {
 	header := make([]byte, maxLogRecordHeaderSize)
 
-	// 第五个字节存储 Type
-	header[4] = byte(record.Type)
+	// 第五个字节存储 Type 和标志位
+	var typ = byte(record.Type)
+	if record.Expire > 0 {
+		typ |= flagExpire
+	}
+	if len(record.Namespace) > 0 {
+		typ |= flagNamespace
+	}
+	if record.Codec != 0 {
+		typ |= flagCodec
+	}
+	if record.KeyId != 0 {
+		typ |= flagEncrypted
+	}
+	if record.ValueInBlob {
+		typ |= flagBlob
+	}
+	header[4] = typ
 	var index = 5
 	// 5 字节之后存储 key、value 的长度
 	index += binary.PutVarint(header[index:], int64(len(record.Key)))
 	index += binary.PutVarint(header[index:], int64(len(record.Value)))
+	if record.Expire > 0 {
+		index += binary.PutVarint(header[index:], record.Expire)
+	}
+	if len(record.Namespace) > 0 {
+		index += binary.PutVarint(header[index:], int64(len(record.Namespace)))
+	}
+	if record.Codec != 0 {
+		header[index] = record.Codec
+		index++
+	}
+	if record.KeyId != 0 {
+		index += binary.PutVarint(header[index:], int64(record.KeyId))
+	}
 
-	var size = int64(index) + int64(len(record.Key)) + int64(len(record.Value))
+	var nsSize = len(record.Namespace)
+	var size = int64(index) + int64(nsSize) + int64(len(record.Key)) + int64(len(record.Value))
 	encoded := make([]byte, size)
 
 	// 将 header 拷贝到 encoded 中
 	copy(encoded[:index], header[:index])
-	// 将 key 拷贝到 encoded 中
-	copy(encoded[index:], record.Key)
-	// 将 value 拷贝到 encoded 中
-	copy(encoded[index+len(record.Key):], record.Value)
+	// 将 namespace、key、value 依次拷贝到 encoded 中
+	copy(encoded[index:], record.Namespace)
+	copy(encoded[index+nsSize:], record.Key)
+	copy(encoded[index+nsSize+len(record.Key):], record.Value)
 
 	// 计算 crc 校验值
 	crc := crc32.ChecksumIEEE(encoded[4:])
@@ -81,8 +137,10 @@ func decodeLogRecordHeader(buf []byte) (*logRecordHeader, int64) {
 	}
 	header := logRecordHeader{
 		crc:        binary.LittleEndian.Uint32(buf[:n]),
-		recordType: buf[n],
+		recordType: buf[n] & logRecordTypeMask,
 	}
+	var flags = buf[n] &^ logRecordTypeMask
+	header.valueInBlob = flags&flagBlob != 0
 	var index = 5
 	// 读取 key 和 value 的长度
 	var keySize, keyLen = binary.Varint(buf[index:])
@@ -93,6 +151,26 @@ func decodeLogRecordHeader(buf []byte) (*logRecordHeader, int64) {
 	header.valueSize = uint32(valueSize)
 	index += valueLen
 
+	if flags&flagExpire != 0 {
+		var expire, expireLen = binary.Varint(buf[index:])
+		header.expire = expire
+		index += expireLen
+	}
+	if flags&flagNamespace != 0 {
+		var nsSize, nsLen = binary.Varint(buf[index:])
+		header.namespaceSize = uint32(nsSize)
+		index += nsLen
+	}
+	if flags&flagCodec != 0 && index < len(buf) {
+		header.codec = buf[index]
+		index++
+	}
+	if flags&flagEncrypted != 0 {
+		var keyId, keyIdLen = binary.Varint(buf[index:])
+		header.keyId = uint32(keyId)
+		index += keyIdLen
+	}
+
 	return &header, int64(index)
 }
 
@@ -102,22 +180,57 @@ func getLogRecordCRC(record *LogRecord, header []byte) uint32 {
 	}
 
 	crc := crc32.ChecksumIEEE(header[:])
+	crc = crc32.Update(crc, crc32.IEEETable, record.Namespace)
 	crc = crc32.Update(crc, crc32.IEEETable, record.Key)
 	crc = crc32.Update(crc, crc32.IEEETable, record.Value)
 	return crc
 }
 
 // EncodeLogRecordPos 对 LogRecordPos 进行编码，返回编码后的字节数组和字节数组的长度
+// 过期时间仅在设置时才编码，兼容旧的编码格式
 func EncodeLogRecordPos(pos *LogRecordPos) []byte {
-	buf := make([]byte, binary.MaxVarintLen32*2+binary.MaxVarintLen64)
+	buf := make([]byte, binary.MaxVarintLen32*2+binary.MaxVarintLen64*2)
 	var index = 0
 	index += binary.PutVarint(buf[index:], int64(pos.Fid))
 	index += binary.PutVarint(buf[index:], pos.Offset)
 	index += binary.PutVarint(buf[index:], int64(pos.Size))
+	if pos.Expire > 0 {
+		index += binary.PutVarint(buf[index:], pos.Expire)
+	}
 	//return buf[:index], int64(index)
 	return buf[:index]
 }
 
+// EncodeBlobHint 编码 value 存储在 blob 文件中的数据的索引信息，包括记录的位置和 blob 文件中的位置
+func EncodeBlobHint(pos, blobPos *LogRecordPos) []byte {
+	encodedPos := EncodeLogRecordPos(pos)
+	buf := binary.AppendUvarint(nil, uint64(len(encodedPos)))
+	buf = append(buf, encodedPos...)
+	return append(buf, EncodeLogRecordPos(blobPos)...)
+}
+
+// DecodeBlobHint 对 EncodeBlobHint 编码的字节数组进行解码
+func DecodeBlobHint(buf []byte) (*LogRecordPos, *LogRecordPos) {
+	size, n := binary.Uvarint(buf)
+	buf = buf[n:]
+	return DecodeLogRecordPos(buf[:size]), DecodeLogRecordPos(buf[size:])
+}
+
+// EncodeRangeHint 编码范围删除记录的索引信息，包括记录的位置和范围的上界
+func EncodeRangeHint(pos *LogRecordPos, end []byte) []byte {
+	encodedPos := EncodeLogRecordPos(pos)
+	buf := binary.AppendUvarint(nil, uint64(len(encodedPos)))
+	buf = append(buf, encodedPos...)
+	return append(buf, end...)
+}
+
+// DecodeRangeHint 对 EncodeRangeHint 编码的字节数组进行解码
+func DecodeRangeHint(buf []byte) (*LogRecordPos, []byte) {
+	size, n := binary.Uvarint(buf)
+	buf = buf[n:]
+	return DecodeLogRecordPos(buf[:size]), buf[size:]
+}
+
 // DecodeLogRecordPos 对字节数组进行解码，返回 LogRecordPos
 func DecodeLogRecordPos(buf []byte) *LogRecordPos {
 	var index = 0
@@ -125,10 +238,16 @@ func DecodeLogRecordPos(buf []byte) *LogRecordPos {
 	index += n
 	offset, n := binary.Varint(buf[index:])
 	index += n
-	size, _ := binary.Varint(buf[index:])
+	size, n := binary.Varint(buf[index:])
+	index += n
+	var expire int64
+	if index < len(buf) {
+		expire, _ = binary.Varint(buf[index:])
+	}
 	return &LogRecordPos{
 		Fid:    uint32(fid),
 		Offset: offset,
 		Size:   uint32(size),
+		Expire: expire,
 	}
 }
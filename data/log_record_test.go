@@ -1,6 +1,7 @@
 package data
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
 )
@@ -60,6 +61,18 @@ func TestEncodeLogRecord(t *testing.T) {
 			want:  []byte{149, 183, 133, 25, 1, 6, 6, 107, 101, 121, 118, 97, 108},
 			want1: 13,
 		},
+		{
+			name: "with expire",
+			args: args{
+				record: &LogRecord{
+					Key:    []byte("key"),
+					Value:  []byte("val"),
+					Expire: 100,
+				},
+			},
+			want:  []byte{77, 81, 30, 32, 128, 6, 6, 200, 1, 107, 101, 121, 118, 97, 108},
+			want1: 15,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -136,6 +149,20 @@ func Test_decodeLogRecordHeader(t *testing.T) {
 			},
 			want1: 7,
 		},
+		{
+			name: "with expire",
+			args: args{
+				buf: []byte{77, 81, 30, 32, 128, 6, 6, 200, 1, 107, 101, 121, 118, 97, 108},
+			},
+			want: &logRecordHeader{
+				crc:        538857805,
+				recordType: LogRecordTypeNormal,
+				keySize:    3,
+				valueSize:  3,
+				expire:     100,
+			},
+			want1: 9,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -214,3 +241,51 @@ func Test_getLogRecordCRC(t *testing.T) {
 		})
 	}
 }
+
+func TestLogRecordPos_Encode(t *testing.T) {
+	tests := []struct {
+		name string
+		pos  *LogRecordPos
+	}{
+		{
+			name: "without expire",
+			pos:  &LogRecordPos{Fid: 1, Offset: 100, Size: 20},
+		},
+		{
+			name: "with expire",
+			pos:  &LogRecordPos{Fid: 1, Offset: 100, Size: 20, Expire: 1700000000000000000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DecodeLogRecordPos(EncodeLogRecordPos(tt.pos)); !reflect.DeepEqual(got, tt.pos) {
+				t.Errorf("DecodeLogRecordPos() = %v, want %v", got, tt.pos)
+			}
+		})
+	}
+}
+
+func TestBlobHint_Encode(t *testing.T) {
+	pos := &LogRecordPos{Fid: 1, Offset: 100, Size: 20, Expire: 1700000000000000000}
+	blobPos := &LogRecordPos{Fid: 300, Offset: 1 << 40, Size: 1 << 20}
+	gotPos, gotBlobPos := DecodeBlobHint(EncodeBlobHint(pos, blobPos))
+	if !reflect.DeepEqual(gotPos, pos) {
+		t.Errorf("DecodeBlobHint() pos = %v, want %v", gotPos, pos)
+	}
+	if !reflect.DeepEqual(gotBlobPos, blobPos) {
+		t.Errorf("DecodeBlobHint() blobPos = %v, want %v", gotBlobPos, blobPos)
+	}
+}
+
+func TestRangeHint_Encode(t *testing.T) {
+	pos := &LogRecordPos{Fid: 2, Offset: 300, Size: 18}
+	for _, end := range [][]byte{nil, []byte("user0")} {
+		gotPos, gotEnd := DecodeRangeHint(EncodeRangeHint(pos, end))
+		if !reflect.DeepEqual(gotPos, pos) {
+			t.Errorf("DecodeRangeHint() pos = %v, want %v", gotPos, pos)
+		}
+		if !bytes.Equal(gotEnd, end) {
+			t.Errorf("DecodeRangeHint() end = %q, want %q", gotEnd, end)
+		}
+	}
+}
@@ -1,19 +1,22 @@
 package bitcask_go
 
 import (
+	"context"
+	"crypto/aes"
 	"errors"
 	"fmt"
 	"github.com/xiecang/bitcask/data"
 	"github.com/xiecang/bitcask/fio"
 	"github.com/xiecang/bitcask/index"
 	"github.com/xiecang/bitcask/utils"
-	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gofrs/flock"
 )
@@ -27,16 +30,43 @@ const (
 type DB struct {
 	options            Options
 	mu                 *sync.RWMutex
-	activeFile         *data.File            // 活跃数据文件, 可以用于写入
-	olderFiles         map[uint32]*data.File // 旧数据文件, 只能用于读取
-	index              index.Indexer         // 内存索引
-	seqId              uint64                // 事务序列号，全局递增
-	isMerging          bool                  // 是否正在合并数据文件
-	isInitial          bool                  // 是否已经初始化
-	isSeqIdFileNotExit bool                  // 存储事务最大 id 的文件是否不存在
-	fileLock           *flock.Flock          // 文件锁, 防止多个进程同时打开数据库
-	bytesWrite         uint                  // 未执行 sync 前，累计写入的字节数
-	reclaimableSize    int64                 // 可以进行 merge 回收的数据量，单位 byte
+	activeFile         *data.File                           // 活跃数据文件, 可以用于写入
+	olderFiles         map[uint32]*data.File                // 旧数据文件, 只能用于读取
+	index              index.Indexer                        // 内存索引
+	seqId              uint64                               // 事务序列号，全局递增
+	isMerging          bool                                 // 是否正在合并数据文件
+	isInitial          bool                                 // 是否已经初始化
+	isSeqIdFileNotExit bool                                 // 存储事务最大 id 的文件是否不存在
+	fileLock           *flock.Flock                         // 文件锁, 防止多个进程同时打开数据库
+	bytesWrite         uint                                 // 未执行 sync 前，累计写入的字节数
+	reclaimableSize    int64                                // 可以进行 merge 回收的数据量，单位 byte
+	snapshots          map[*Snapshot]struct{}               // 尚未释放的快照
+	txns               map[*Txn]struct{}                    // 尚未结束的事务
+	committedWrites    []*committedWrite                    // 事务进行期间已提交的写入，用于冲突检测
+	watchers           map[*watcher]struct{}                // 变更订阅者
+	namespaces         map[string]*Namespace                // 命名空间，每个命名空间拥有独立的内存索引
+	pendingTxnRecords  map[uint64][]*data.TransactionRecord // 只读模式下尚未读取到完成标记的事务数据
+	mergeFinishedTime  int64                                // 只读模式下打开时 merge 完成文件的修改时间，用于判断写入方是否应用了新的 merge 结果
+	closeCh            chan struct{}                        // 数据库关闭时关闭该通道，通知后台任务退出
+	bgWait             sync.WaitGroup                       // 等待后台任务退出
+	autoMergeCount     uint                                 // 后台自动 merge 成功的次数
+	lastAutoMergeTime  time.Time                            // 最近一次后台自动 merge 的时间
+	lastAutoMergeErr   error                                // 最近一次后台自动 merge 的错误
+	writeMu            *sync.Mutex                          // 保护等待组提交的写入队列
+	writeCond          *sync.Cond                           // 组提交完成时唤醒等待的写入方
+	writeQueue         []*writeRequest                      // 等待组提交的写入
+	syncing            bool                                 // 组提交是否正在释放 db.mu 执行 sync，此时其他写入方需要等待
+	syncDone           *sync.Cond                           // 组提交的 sync 完成时唤醒等待的写入方，使用 db.mu 的写锁
+	compressor         Compressor                           // 写入时使用的压缩算法，为 nil 时不压缩
+	compressors        map[CompressionType]Compressor       // 读取时可以使用的所有压缩算法
+	encryptor          *encryptor                           // 加密数据文件中的 key 和 value，为 nil 时不加密
+	activeHint         []byte                               // 活跃数据文件中所有记录的索引信息，数据文件写满或关闭时写入 hint 文件
+	activeBlobFile     *data.File                           // 活跃 blob 文件，用于写入超过 LargeValueThreshold 的 value
+	blobFiles          map[uint32]*data.File                // 所有的 blob 文件，包括活跃 blob 文件
+	blobRefs           map[blobRef]*data.LogRecordPos       // 有效数据记录的位置到 value 在 blob 文件中位置的映射
+	blobGarbage        map[uint32]int64                     // 每个 blob 文件中可以回收的数据量，单位 byte
+	checkpoints        int                                  // 正在创建的检查点数量，创建期间不回收 blob 文件
+	iterators          int32                                // 尚未关闭的迭代器数量，存在时不回收 blob 文件，只读模式下不重新加载数据文件
 }
 
 // Stat 存储引擎的统计信息
@@ -45,6 +75,17 @@ type Stat struct {
 	DataFileNum     uint  // 数据文件的数量
 	ReclaimableSize int64 // 可以进行 merge 回收的数据量，单位 byte
 	DiskSize        int64 // 数据目录占用的磁盘空间，单位 byte
+
+	AutoMergeCount     uint      // 后台自动 merge 成功的次数
+	LastAutoMergeTime  time.Time // 最近一次后台自动 merge 的时间
+	LastAutoMergeError string    // 最近一次后台自动 merge 的错误信息，成功时为空
+	MergePending       bool      // 是否有已经完成但尚未加载的 merge 结果，重新打开数据库之后生效
+
+	BlobFileNum         uint  // blob 文件的数量
+	BlobSize            int64 // blob 文件的总大小，单位 byte
+	BlobReclaimableSize int64 // blob 文件中可以回收的数据量，单位 byte
+
+	StatError string // 统计磁盘空间失败时的错误信息，此时对应的大小为 0，成功时为空
 }
 
 func fileLockPath(dirPath string) string {
@@ -52,26 +93,40 @@ func fileLockPath(dirPath string) string {
 }
 
 // Open 打开 bitcask 数据库存储引擎
-func Open(options Options) (*DB, error) {
+func Open(options Options) (_ *DB, err error) {
 	if err := checkOptions(&options); err != nil {
 		return nil, err
 	}
+	var start = time.Now()
 
 	var isInitial bool
 	// 判断数据目录是否存在，如果不存在的话就创建这个目录
 	if _, err := os.Stat(options.DirPath); os.IsNotExist(err) {
+		if options.ReadOnly {
+			// 只读模式下不创建任何文件
+			return nil, err
+		}
 		if err = os.MkdirAll(options.DirPath, os.ModePerm); err != nil {
 			return nil, err
 		}
 		isInitial = true
 	}
 
-	// 判断当前文件是否在正在使用
-	fileLock := flock.New(fileLockPath(options.DirPath))
-	if hold, err := fileLock.TryLock(); err != nil {
-		return nil, err
-	} else if !hold {
-		return nil, ErrDatabaseIsUsing
+	// 判断当前文件是否在正在使用，只读模式下不加锁，允许和写入方以及其他只读实例同时打开
+	var fileLock *flock.Flock
+	if !options.ReadOnly {
+		fileLock = flock.New(fileLockPath(options.DirPath))
+		if hold, err := fileLock.TryLock(); err != nil {
+			return nil, err
+		} else if !hold {
+			return nil, ErrDatabaseIsUsing
+		}
+		// 打开失败时释放文件锁
+		defer func() {
+			if err != nil {
+				_ = fileLock.Unlock()
+			}
+		}()
 	}
 
 	if entries, err := os.ReadDir(options.DirPath); err != nil {
@@ -81,24 +136,41 @@ func Open(options Options) (*DB, error) {
 	}
 
 	db := DB{
-		options:    options,
-		mu:         &sync.RWMutex{},
-		olderFiles: make(map[uint32]*data.File),
-		index:      index.NewIndexer(options.IndexType, options.DirPath, options.SyncWrites),
-		isInitial:  isInitial,
-		fileLock:   fileLock,
+		options:     options,
+		mu:          &sync.RWMutex{},
+		olderFiles:  make(map[uint32]*data.File),
+		snapshots:   make(map[*Snapshot]struct{}),
+		txns:        make(map[*Txn]struct{}),
+		watchers:    make(map[*watcher]struct{}),
+		namespaces:  make(map[string]*Namespace),
+		blobFiles:   make(map[uint32]*data.File),
+		blobRefs:    make(map[blobRef]*data.LogRecordPos),
+		blobGarbage: make(map[uint32]int64),
+		closeCh:     make(chan struct{}),
+		writeMu:     &sync.Mutex{},
+		index:       index.NewIndexer(options.IndexType, options.DirPath, options.SyncWrites),
+		isInitial:   isInitial,
+		fileLock:    fileLock,
+	}
+	db.writeCond = sync.NewCond(db.writeMu)
+	db.syncDone = sync.NewCond(db.mu)
+	db.compressors, db.compressor = newCompressors(options)
+	db.encryptor = newEncryptor(options)
+
+	// 加载 merge 数据目录，只读模式下不修改数据目录，由写入方在下次启动时加载
+	if !options.ReadOnly {
+		if err := db.loadMergeFiles(); err != nil {
+			return nil, err
+		}
+	} else {
+		db.mergeFinishedTime = mergeFinishedTime(options.DirPath)
 	}
 
-	// 加载 merge 数据目录
-	if err := db.loadMergeFiles(); err != nil {
+	// 加载数据文件和 blob 文件
+	if err = db.loadBlobFiles(); err != nil {
 		return nil, err
 	}
-
-	// 加载数据文件
-	var (
-		err     error
-		fileIds []int
-	)
+	var fileIds []int
 	if fileIds, err = db.loadDataFiles(); err != nil {
 		return nil, err
 	}
@@ -144,6 +216,10 @@ func Open(options Options) (*DB, error) {
 			return nil, err
 		}
 
+		if err = db.computeBlobGarbage(); err != nil {
+			return nil, err
+		}
+
 		// 重置 io 类型为标准文件 IO (如果实现了 mmap 的 write 和 sync 方法的话，也可不重置)
 		if db.options.MMapAtStartup {
 			if err = db.resetIOType(); err != nil {
@@ -152,49 +228,246 @@ func Open(options Options) (*DB, error) {
 		}
 	}
 
+	// 启动后台自动 merge 和 blob 文件回收任务
+	if options.AutoMergeInterval > 0 && !options.ReadOnly {
+		db.startAutoMerge()
+	}
+	if options.BlobGCInterval > 0 && !options.ReadOnly {
+		db.startBlobGC()
+	}
+	db.observeIndexSize()
+
+	var dataFiles = len(db.olderFiles)
+	if db.activeFile != nil {
+		dataFiles++
+	}
+	db.logger().Info("database opened",
+		"dir", options.DirPath,
+		"data_files", dataFiles,
+		"blob_files", len(db.blobFiles),
+		"keys", db.index.Size(),
+		"read_only", options.ReadOnly,
+		"duration", time.Since(start))
 	return &db, nil
 }
 
 // Put 写入 key-value 数据，key 不能为空
 func (db *DB) Put(key []byte, value []byte) error {
+	return db.put(nil, key, value, 0)
+}
+
+// PutWithTTL 写入 key-value 数据，并设置过期时间，过期后的数据不可见
+func (db *DB) PutWithTTL(key []byte, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+	return db.put(nil, key, value, time.Now().Add(ttl).UnixNano())
+}
+
+// put 写入 key-value 数据到命名空间 ns 中，ns 为 nil 时写入默认命名空间
+func (db *DB) put(ns *Namespace, key []byte, value []byte, expire int64) error {
+	defer db.observeOperation(MetricPutTotal, MetricPutDuration, time.Now())
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
 	//
 	record := data.LogRecord{
-		Key:   logRecordKeyWithSeq(key, nonTransactionSeqId),
-		Value: value,
-		Type:  data.LogRecordTypeNormal,
+		Key:       logRecordKeyWithSeq(key, nonTransactionSeqId),
+		Value:     value,
+		Type:      data.LogRecordTypeNormal,
+		Expire:    expire,
+		Namespace: namespaceName(ns),
+	}
+	return db.appendLogRecordWithLock(&record, func(pos *data.LogRecordPos) error {
+		db.applyPut(ns, key, value, pos)
+		return nil
+	})
+}
+
+// putLocked 写入 key-value 数据并更新内存索引，需要持有 db.mu
+func (db *DB) putLocked(key []byte, value []byte, expire int64) error {
+	record := data.LogRecord{
+		Key:    logRecordKeyWithSeq(key, nonTransactionSeqId),
+		Value:  value,
+		Type:   data.LogRecordTypeNormal,
+		Expire: expire,
 	}
-	pos, err := db.appendLogRecordWithLock(&record)
+	pos, err := db.appendLogRecord(&record)
 	if err != nil {
 		return err
 	}
-	// 更新内存索引
-	if oldPos := db.index.Put(key, pos); oldPos != nil {
-		db.reclaimableSize += int64(oldPos.Size)
+	db.applyPut(nil, key, value, pos)
+	return nil
+}
+
+// deleteLocked 写入删除记录并更新内存索引，需要持有 db.mu
+func (db *DB) deleteLocked(key []byte) error {
+	record := data.LogRecord{
+		Key:  logRecordKeyWithSeq(key, nonTransactionSeqId),
+		Type: data.LogRecordTypeDelete,
+	}
+	pos, err := db.appendLogRecord(&record)
+	if err != nil {
+		return err
+	}
+	return db.applyDelete(nil, key, pos)
+}
+
+// applyPut 写入数据之后更新命名空间 ns 的内存索引，需要持有 db.mu
+func (db *DB) applyPut(ns *Namespace, key, value []byte, pos *data.LogRecordPos) {
+	if oldPos := db.indexOf(ns).Put(key, pos); oldPos != nil {
+		db.discardPos(ns, oldPos)
+	}
+	seqId := db.nonTxnWriteSeqId()
+	if ns == nil {
+		// 事务只作用于默认命名空间
+		db.trackWrites(seqId, key)
+		db.observeIndexSize()
+	}
+	if len(db.watchers) > 0 {
+		db.notifyWatchers([]WatchEvent{{
+			Type:      WatchEventPut,
+			Key:       key,
+			Value:     value,
+			SeqId:     seqId,
+			Namespace: namespaceName(ns),
+		}})
+	}
+}
+
+// applyDelete 写入删除记录之后从命名空间 ns 的内存索引中删除 key，需要持有 db.mu
+func (db *DB) applyDelete(ns *Namespace, key []byte, pos *data.LogRecordPos) error {
+	// 删除记录本身也是可以回收的
+	db.discardPos(ns, pos)
+
+	oldPos, ok := db.indexOf(ns).Delete(key)
+	if !ok {
+		return ErrIndexUpdateFailed
+	}
+	if oldPos != nil {
+		db.discardPos(ns, oldPos)
+	}
+	seqId := db.nonTxnWriteSeqId()
+	if ns == nil {
+		db.trackWrites(seqId, key)
+		db.observeIndexSize()
+	}
+	if len(db.watchers) > 0 {
+		db.notifyWatchers([]WatchEvent{{
+			Type:      WatchEventDelete,
+			Key:       key,
+			SeqId:     seqId,
+			Namespace: namespaceName(ns),
+		}})
 	}
 	return nil
 }
 
+// nonTxnWriteSeqId 非事务写入没有序列号，当事务或订阅者需要区分写入的先后顺序时，
+// 递增全局序列号作为本次写入的版本，需要持有 db.mu
+func (db *DB) nonTxnWriteSeqId() uint64 {
+	if len(db.txns) == 0 && len(db.watchers) == 0 {
+		return nonTransactionSeqId
+	}
+	return atomic.AddUint64(&db.seqId, 1)
+}
+
 // Get 根据 key 读取数据
 func (db *DB) Get(key []byte) ([]byte, error) {
+	defer db.observeOperation(MetricGetTotal, MetricGetDuration, time.Now())
 	db.mu.Lock()
 	defer db.mu.Unlock()
+	return db.getFromIndex(db.index, key)
+}
+
+// getFromIndex 从指定的内存索引中查找 key 并读取数据，需要持有 db.mu
+func (db *DB) getFromIndex(indexer index.Indexer, key []byte) ([]byte, error) {
 	if len(key) == 0 {
 		return nil, ErrKeyIsEmpty
 	}
 	// 从内存数据结构中取出 key 对应的索引信息
-	pos := db.index.Get(key)
-	if pos == nil {
+	pos := indexer.Get(key)
+	if pos == nil || pos.IsExpired(time.Now().UnixNano()) {
 		return nil, ErrKeyNotFound
 	}
 
 	return db.getValueByPosition(pos)
 }
 
-// getValueByPosition 根据索引信息读取 value
+// Expire 为已存在的 key 设置过期时间
+func (db *DB) Expire(key []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+	return db.resetExpire(key, time.Now().Add(ttl).UnixNano())
+}
+
+// Persist 移除 key 的过期时间，使其永不过期
+func (db *DB) Persist(key []byte) error {
+	return db.resetExpire(key, 0)
+}
+
+// TTL 返回 key 剩余的存活时间，如果 key 没有设置过期时间，返回 -1
+func (db *DB) TTL(key []byte) (time.Duration, error) {
+	if len(key) == 0 {
+		return 0, ErrKeyIsEmpty
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var now = time.Now().UnixNano()
+	pos := db.index.Get(key)
+	if pos == nil || pos.IsExpired(now) {
+		return 0, ErrKeyNotFound
+	}
+	if pos.Expire == 0 {
+		return -1, nil
+	}
+	return time.Duration(pos.Expire - now), nil
+}
+
+// resetExpire 使用新的过期时间重新写入 key 对应的数据
+func (db *DB) resetExpire(key []byte, expire int64) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+	db.lockForWrite()
+	defer db.mu.Unlock()
+
+	pos := db.index.Get(key)
+	if pos == nil || pos.IsExpired(time.Now().UnixNano()) {
+		return ErrKeyNotFound
+	}
+	if pos.Expire == expire {
+		return nil
+	}
+	value, err := db.getValueByPosition(pos)
+	if err != nil {
+		return err
+	}
+	return db.putLocked(key, value, expire)
+}
+
+// getValueByPosition 根据索引信息读取 value，value 存储在 blob 文件中时从 blob 文件中读取
 func (db *DB) getValueByPosition(pos *data.LogRecordPos) ([]byte, error) {
+	record, err := db.readLogRecord(pos)
+	if err != nil {
+		return nil, err
+	}
+	if record.Type == data.LogRecordTypeDelete {
+		return nil, ErrFileNotFound
+	}
+	if record.ValueInBlob {
+		return db.readBlob(data.DecodeLogRecordPos(record.Value))
+	}
+	if err = db.decompressRecord(record); err != nil {
+		return nil, err
+	}
+	return record.Value, nil
+}
+
+// readLogRecord 读取 pos 位置的记录并解密
+func (db *DB) readLogRecord(pos *data.LogRecordPos) (*data.LogRecord, error) {
 	// 根据文件 Id 找到对应的数据文件
 	var file *data.File
 	if db.activeFile.Id == pos.Fid {
@@ -211,36 +484,49 @@ func (db *DB) getValueByPosition(pos *data.LogRecordPos) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-
-	if record.Type == data.LogRecordTypeDelete {
-		return nil, ErrFileNotFound
+	if err = db.decryptRecord(record); err != nil {
+		return nil, err
 	}
-	return record.Value, nil
+	return record, nil
 }
 
-// ListKeys 列出数据库中所有的 key
+// ListKeys 列出数据库中所有的 key, 已过期的 key 不会列出
 func (db *DB) ListKeys() [][]byte {
 	iterator := db.index.Iterator(false)
 	defer iterator.Close()
-	keys := make([][]byte, db.index.Size())
-	var i int
+	keys := make([][]byte, 0, db.index.Size())
+	var now = time.Now().UnixNano()
 	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
-		keys[i] = iterator.Key()
-		i++
+		if iterator.Value().IsExpired(now) {
+			continue
+		}
+		keys = append(keys, iterator.Key())
 	}
 	return keys
 }
 
 // Fold 遍历数据库中的所有 key-value, fn 返回 true 时继续遍历，返回 false 时停止遍历
 func (db *DB) Fold(fn func(key, value []byte) bool) error {
+	return db.FoldContext(context.Background(), fn)
+}
+
+// FoldContext 与 Fold 相同，ctx 取消时停止遍历并返回 ctx.Err()
+func (db *DB) FoldContext(ctx context.Context, fn func(key, value []byte) bool) error {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	iterator := db.index.Iterator(false)
 	defer iterator.Close()
 
+	var now = time.Now().UnixNano()
 	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		pos := iterator.Value()
+		if pos.IsExpired(now) {
+			continue
+		}
 		value, err := db.getValueByPosition(pos)
 		if err != nil {
 			return err
@@ -257,13 +543,11 @@ func (db *DB) saveSeqIdToFile() error {
 	if err != nil {
 		return err
 	}
-	record := data.LogRecord{
-		Key:   []byte(seqIdKey),
-		Value: []byte(strconv.FormatUint(db.seqId, 10)),
-		Type:  data.LogRecordTypeSeqId,
+	record, err := db.seqIdRecord()
+	if err != nil {
+		return err
 	}
-	encodeRecord, _ := data.EncodeLogRecord(&record)
-	if err = seqIdFile.Write(encodeRecord); err != nil {
+	if err = seqIdFile.WriteLogRecord(record); err != nil {
 		return err
 	}
 	if err = seqIdFile.Sync(); err != nil {
@@ -275,6 +559,15 @@ func (db *DB) saveSeqIdToFile() error {
 	return err
 }
 
+// seqIdRecord 返回记录当前事务序列号的记录，需要持有 db.mu
+func (db *DB) seqIdRecord() (*data.LogRecord, error) {
+	return db.encryptRecord(&data.LogRecord{
+		Key:   []byte(seqIdKey),
+		Value: []byte(strconv.FormatUint(db.seqId, 10)),
+		Type:  data.LogRecordTypeSeqId,
+	})
+}
+
 func (db *DB) loadSeqId() error {
 	if data.IsSeqIdFileNotExit(db.options.DirPath) {
 		db.isSeqIdFileNotExit = true
@@ -288,6 +581,9 @@ func (db *DB) loadSeqId() error {
 	if err != nil {
 		return err
 	}
+	if err = db.decryptRecord(r); err != nil {
+		return err
+	}
 	id, err := strconv.ParseInt(string(r.Value), 10, 64)
 	if err != nil {
 		return err
@@ -297,26 +593,67 @@ func (db *DB) loadSeqId() error {
 }
 
 // Close 关闭数据库
-func (db *DB) Close() error {
+func (db *DB) Close() (err error) {
 	defer func() {
+		if err != nil {
+			db.logger().Error("close database failed", "dir", db.options.DirPath, "error", err)
+			return
+		}
+		var activeFileId uint32
+		if db.activeFile != nil {
+			activeFileId = db.activeFile.Id
+		}
+		db.logger().Info("database closed", "dir", db.options.DirPath, "active_file_id", activeFileId)
+	}()
+	defer func() {
+		if db.fileLock == nil {
+			return
+		}
 		if err := db.fileLock.Unlock(); err != nil {
 			panic(fmt.Sprintf("unlock file lock failed: %s", err))
 		}
 	}()
-	db.mu.Lock()
+	// 通知后台任务退出，并等待正在执行的后台任务完成
+	select {
+	case <-db.closeCh:
+	default:
+		close(db.closeCh)
+	}
+	db.bgWait.Wait()
+
+	db.lockForWrite()
 	defer db.mu.Unlock()
 
+	// 关闭所有的订阅
+	for w := range db.watchers {
+		db.removeWatcher(w)
+	}
+
 	if err := db.index.Close(); err != nil {
 		return err
 	}
+	for _, ns := range db.namespaces {
+		if err := ns.index.Close(); err != nil {
+			return err
+		}
+	}
 
 	if db.activeFile == nil {
 		return nil
 	}
 
 	// 保存当前事务序列号
-	if err := db.saveSeqIdToFile(); err != nil {
-		return err
+	if !db.options.ReadOnly {
+		if err := db.saveSeqIdToFile(); err != nil {
+			return err
+		}
+	}
+
+	// 为当前活跃文件生成 hint 文件，下次启动时不需要扫描数据文件
+	if db.dataHintEnabled() {
+		if err := db.writeDataHint(db.activeFile.Id); err != nil {
+			return err
+		}
 	}
 
 	// 关闭当前活跃文件
@@ -330,6 +667,13 @@ func (db *DB) Close() error {
 			return err
 		}
 	}
+
+	// 关闭 blob 文件
+	for _, file := range db.blobFiles {
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -338,44 +682,43 @@ func (db *DB) Sync() error {
 	if db.activeFile == nil {
 		return nil
 	}
-	db.mu.Lock()
+	db.lockForWrite()
 	defer db.mu.Unlock()
 
-	return db.activeFile.Sync()
+	return db.syncActiveFile()
 }
 
 // Delete 根据 key 删除对应的数据
 func (db *DB) Delete(key []byte) error {
+	return db.delete(nil, key)
+}
+
+// delete 删除命名空间 ns 中 key 对应的数据，ns 为 nil 时表示默认命名空间
+func (db *DB) delete(ns *Namespace, key []byte) error {
+	defer db.observeOperation(MetricDeleteTotal, MetricDeleteDuration, time.Now())
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
+	if db.options.ReadOnly {
+		return ErrReadOnly
+	}
 
 	// 先检查 key 是否存在，如果不存在的话就直接返回
-	if pos := db.index.Get(key); pos == nil {
+	if pos := db.indexOf(ns).Get(key); pos == nil {
 		return nil
 	}
 
 	// 构造删除数据的记录
 	record := data.LogRecord{
-		Key:  logRecordKeyWithSeq(key, nonTransactionSeqId),
-		Type: data.LogRecordTypeDelete,
+		Key:       logRecordKeyWithSeq(key, nonTransactionSeqId),
+		Type:      data.LogRecordTypeDelete,
+		Namespace: namespaceName(ns),
 	}
 	// 写入到数据文件中
-	pos, err := db.appendLogRecordWithLock(&record)
-	if err != nil {
-		return err
-	}
-	db.reclaimableSize += int64(pos.Size)
-
-	// 从内存索引中将对应的 key 删除
-	oldPos, ok := db.index.Delete(key)
-	if !ok {
-		return ErrIndexUpdateFailed
-	}
-	if oldPos != nil {
-		db.reclaimableSize += int64(oldPos.Size)
-	}
-	return nil
+	return db.appendLogRecordWithLock(&record, func(pos *data.LogRecordPos) error {
+		// 从内存索引中将对应的 key 删除
+		return db.applyDelete(ns, key, pos)
+	})
 }
 
 // Stat 返回数据库的统计信息
@@ -387,24 +730,60 @@ func (db *DB) Stat() *Stat {
 	if db.activeFile != nil {
 		fileNum++
 	}
+	// 统计磁盘空间失败时不影响其他统计信息，错误信息记录在 StatError 中
+	var statError string
 	diskSize, err := utils.DirSize(db.options.DirPath)
 	if err != nil {
-		panic(fmt.Sprintf("get dir size failed: %v", err))
+		statError = fmt.Sprintf("get dir size failed: %v", err)
+	}
+	blobSize, blobReclaimableSize, err := db.blobStat()
+	if err != nil {
+		statError = fmt.Sprintf("get blob file size failed: %v", err)
+	}
+	var lastAutoMergeError string
+	if db.lastAutoMergeErr != nil {
+		lastAutoMergeError = db.lastAutoMergeErr.Error()
 	}
 	return &Stat{
-		KeyNum:          uint(db.index.Size()),
-		DataFileNum:     fileNum,
-		ReclaimableSize: db.reclaimableSize,
-		DiskSize:        diskSize,
+		KeyNum:             uint(db.index.Size()),
+		DataFileNum:        fileNum,
+		ReclaimableSize:    db.reclaimableSize,
+		DiskSize:           diskSize,
+		AutoMergeCount:     db.autoMergeCount,
+		LastAutoMergeTime:  db.lastAutoMergeTime,
+		LastAutoMergeError: lastAutoMergeError,
+		MergePending:       db.mergePending(),
+
+		BlobFileNum:         uint(len(db.blobFiles)),
+		BlobSize:            blobSize,
+		BlobReclaimableSize: blobReclaimableSize,
+
+		StatError: statError,
 	}
 }
 
 // Backup 备份数据库, 将数据库文件拷贝到新目录
 func (db *DB) Backup(dir string) error {
+	return db.BackupContext(context.Background(), dir)
+}
+
+// BackupContext 与 Backup 相同，ctx 取消时停止拷贝并返回 ctx.Err()，取消时删除由本次备份创建的目录
+func (db *DB) BackupContext(ctx context.Context, dir string) error {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	return utils.CopyDir(db.options.DirPath, dir, []string{fileLockName})
+	var start = time.Now()
+	_, statErr := os.Stat(dir)
+	if err := utils.CopyDirContext(ctx, db.options.DirPath, dir, []string{fileLockName}); err != nil {
+		if ctx.Err() != nil && os.IsNotExist(statErr) {
+			_ = os.RemoveAll(dir)
+		}
+		db.logger().Error("backup failed", "dir", dir, "error", err)
+		return err
+	}
+	size, _ := utils.DirSize(dir)
+	db.logger().Info("backup finished", "dir", dir, "bytes", size, "duration", time.Since(start))
+	return nil
 }
 
 func (db *DB) shouldSync() bool {
@@ -415,8 +794,40 @@ func (db *DB) shouldSync() bool {
 	return needSync
 }
 
-// appendLogRecord 追加写数据到活跃数据文件中
+// appendLogRecord 追加写数据到活跃数据文件中，并根据配置决定是否持久化
 func (db *DB) appendLogRecord(record *data.LogRecord) (*data.LogRecordPos, error) {
+	pos, err := db.writeLogRecord(record)
+	if err != nil {
+		return nil, err
+	}
+	if db.shouldSync() {
+		if err = db.syncActiveFile(); err != nil {
+			return nil, err
+		}
+	}
+	return pos, nil
+}
+
+// syncActiveFile 持久化活跃数据文件和活跃 blob 文件，并清空累计写入值
+func (db *DB) syncActiveFile() error {
+	defer db.observeOperation(MetricSyncTotal, MetricSyncDuration, time.Now())
+	if db.activeBlobFile != nil {
+		if err := db.activeBlobFile.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := db.activeFile.Sync(); err != nil {
+		return err
+	}
+	db.bytesWrite = 0
+	return nil
+}
+
+// writeLogRecord 追加写数据到活跃数据文件中，不执行持久化
+func (db *DB) writeLogRecord(record *data.LogRecord) (*data.LogRecordPos, error) {
+	if db.options.ReadOnly {
+		return nil, ErrReadOnly
+	}
 
 	// 判断当前活跃数据文件是否存在
 	if db.activeFile == nil {
@@ -425,55 +836,110 @@ func (db *DB) appendLogRecord(record *data.LogRecord) (*data.LogRecordPos, error
 		}
 	}
 
+	// 较大的 value 单独写入 blob 文件，记录中只保留 value 在 blob 文件中的位置
+	var blobPos *data.LogRecordPos
+	if db.shouldStoreInBlob(record) {
+		var err error
+		if blobPos, err = db.writeBlob(record); err != nil {
+			return nil, err
+		}
+		blobRecord := *record
+		blobRecord.Value = data.EncodeLogRecordPos(blobPos)
+		blobRecord.ValueInBlob = true
+		record = &blobRecord
+	}
+
+	// 先压缩 value，再加密 key 和 value
+	var plainRecord = record
+	record, err := db.compressRecord(record)
+	if err != nil {
+		return nil, err
+	}
+	if record, err = db.encryptRecord(record); err != nil {
+		return nil, err
+	}
+
 	// 写入数据编码
 	encodedRecord, size := data.EncodeLogRecord(record)
 	// 如果写入的数据已经达到了活跃文件的阈值，则关闭活跃文件，并打开新的文件
 	if db.activeFile.WriteOffset+size > db.options.MaxFileSize {
-		// 先持久化数据文件，保证已有数据持久化到磁盘当中
-		if err := db.activeFile.Sync(); err != nil {
+		if err := db.rotateActiveFile(); err != nil {
 			return nil, err
 		}
+	}
 
-		// 当前活跃文件转换为旧文件
-		db.olderFiles[db.activeFile.Id] = db.activeFile
-
-		// 打开新的数据文件
-		if err := db.setActivateDataFile(); err != nil {
+	// 构造内存索引信息
+	pos := &data.LogRecordPos{
+		Fid:    db.activeFile.Id,
+		Offset: db.activeFile.WriteOffset,
+		Size:   uint32(size),
+		Expire: record.Expire,
+	}
+	var hintSize = len(db.activeHint)
+	if db.dataHintEnabled() {
+		if err = db.appendDataHint(plainRecord, pos); err != nil {
 			return nil, err
 		}
 	}
-
-	writeOffset := db.activeFile.WriteOffset
 	if err := db.activeFile.Write(encodedRecord); err != nil {
+		db.activeHint = db.activeHint[:hintSize]
 		return nil, err
 	}
 
 	db.bytesWrite += uint(size)
-	if db.shouldSync() {
-		if err := db.activeFile.Sync(); err != nil {
-			return nil, err
-		}
+	db.incrCounter(MetricBytesWritten, uint64(size))
+	if blobPos != nil {
+		db.blobRefs[blobRefOf(pos)] = blobPos
+	}
+	return pos, nil
+}
 
-		// 清空累计写入值
-		if db.bytesWrite > 0 {
-			db.bytesWrite = 0
+// rotateActiveFile 持久化当前活跃文件并生成对应的 hint 文件，然后打开新的活跃文件，需要持有 db.mu
+func (db *DB) rotateActiveFile() error {
+	// 先持久化数据文件，保证已有数据持久化到磁盘当中
+	var start = time.Now()
+	if err := db.activeFile.Sync(); err != nil {
+		return err
+	}
+	db.observeOperation(MetricSyncTotal, MetricSyncDuration, start)
+	if db.dataHintEnabled() {
+		if err := db.writeDataHint(db.activeFile.Id); err != nil {
+			return err
 		}
 	}
 
-	// 构造内存索引信息
-	pos := &data.LogRecordPos{
-		Fid:    db.activeFile.Id,
-		Offset: writeOffset,
-		Size:   uint32(size),
+	// 当前活跃文件转换为旧文件
+	var oldFile = db.activeFile
+	db.olderFiles[oldFile.Id] = oldFile
+	db.incrCounter(MetricFileRotations, 1)
+
+	// 打开新的数据文件
+	if err := db.setActivateDataFile(); err != nil {
+		db.logger().Error("open new data file failed", "file_id", oldFile.Id+1, "error", err)
+		return err
 	}
-	return pos, nil
+	db.logger().Info("data file rotated",
+		"file_id", oldFile.Id,
+		"bytes", oldFile.WriteOffset,
+		"new_file_id", db.activeFile.Id)
+	return nil
 }
 
 // appendLogRecordWithLock 追加写数据到活跃数据文件中
-func (db *DB) appendLogRecordWithLock(record *data.LogRecord) (*data.LogRecordPos, error) {
-	db.mu.Lock()
+// apply 在同一个临界区内执行，用于更新内存索引，保证写入和索引更新的原子性
+// 同步写入时，并发的写入会组成一组，由第一个写入方统一写入并只执行一次 sync
+func (db *DB) appendLogRecordWithLock(record *data.LogRecord, apply func(pos *data.LogRecordPos) error) error {
+	if db.options.SyncWrites && !db.options.DisableGroupCommit {
+		return db.groupCommit(record, apply)
+	}
+
+	db.lockForWrite()
 	defer db.mu.Unlock()
-	return db.appendLogRecord(record)
+	pos, err := db.appendLogRecord(record)
+	if err != nil {
+		return err
+	}
+	return apply(pos)
 }
 
 // setActivateDataFile 设置当前活跃数据文件
@@ -492,28 +958,10 @@ func (db *DB) setActivateDataFile() error {
 	return nil
 }
 func (db *DB) loadDataFiles() ([]int, error) {
-	dirEntries, err := os.ReadDir(db.options.DirPath)
+	fileIds, err := dataFileIds(db.options.DirPath)
 	if err != nil {
 		return nil, err
 	}
-	var fileIds []int
-	// 遍历数据目录下的文件，找到所有以 .data 结尾的数据文件
-	for _, entry := range dirEntries {
-		if !strings.HasSuffix(entry.Name(), data.FileNameSuffix) {
-			continue
-		}
-		// 00000001.data
-		splitNames := strings.Split(entry.Name(), ".")
-		fileId, err := strconv.Atoi(splitNames[0])
-		if err != nil {
-			// 数据目录有可能被损坏了
-			return nil, ErrDataDirectoryCorrupted
-		}
-		fileIds = append(fileIds, fileId)
-	}
-
-	// 对文件 Id 进行排序，从小到大依次加载
-	sort.Ints(fileIds)
 
 	// 遍历文件 Id，加载数据文件
 	for i, fileId := range fileIds {
@@ -536,6 +984,33 @@ func (db *DB) loadDataFiles() ([]int, error) {
 	return fileIds, nil
 }
 
+// dataFileIds 返回数据目录下所有数据文件的 id，从小到大排序
+func dataFileIds(dirPath string) ([]int, error) {
+	dirEntries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	var fileIds []int
+	// 遍历数据目录下的文件，找到所有以 .data 结尾的数据文件
+	for _, entry := range dirEntries {
+		if !strings.HasSuffix(entry.Name(), data.FileNameSuffix) {
+			continue
+		}
+		// 00000001.data
+		splitNames := strings.Split(entry.Name(), ".")
+		fileId, err := strconv.Atoi(splitNames[0])
+		if err != nil {
+			// 数据目录有可能被损坏了
+			return nil, ErrDataDirectoryCorrupted
+		}
+		fileIds = append(fileIds, fileId)
+	}
+
+	// 对文件 Id 进行排序，从小到大依次加载
+	sort.Ints(fileIds)
+	return fileIds, nil
+}
+
 // loadIndexFromDataFiles 从数据文件中加载索引
 // 遍历文件中的所有记录，并更新到内存索引中
 func (db *DB) loadIndexFromDataFiles(fileIds []int) error {
@@ -543,87 +1018,115 @@ func (db *DB) loadIndexFromDataFiles(fileIds []int) error {
 		return nil
 	}
 
-	var updateIndex = func(key []byte, tp data.LogRecordType, pos *data.LogRecordPos) {
+	var now = time.Now().UnixNano()
+	var updateIndex = func(record *data.LogRecord, key []byte, pos *data.LogRecordPos) {
+		ns := db.namespaceOf(record.Namespace)
+		if record.Type == data.LogRecordTypeNamespaceDrop {
+			db.discardPos(ns, pos)
+			db.resetNamespace(ns)
+			return
+		}
+		if record.Type == data.LogRecordTypeRangeDelete {
+			db.discardPos(ns, pos)
+			db.deleteIndexRange(ns, key, record.Value)
+			return
+		}
+
 		var oldPos *data.LogRecordPos
-		if tp == data.LogRecordTypeDelete {
-			oldPos, _ = db.index.Delete(key)
-			db.reclaimableSize += int64(pos.Size)
+		if record.Type == data.LogRecordTypeDelete || pos.IsExpired(now) {
+			// 已过期的数据和删除的数据一样，直接从索引中移除
+			oldPos, _ = db.indexOf(ns).Delete(key)
+			db.discardPos(ns, pos)
 		} else {
-			oldPos = db.index.Put(key, pos)
+			oldPos = db.indexOf(ns).Put(key, pos)
+			if record.ValueInBlob {
+				db.blobRefs[blobRefOf(pos)] = data.DecodeLogRecordPos(record.Value)
+			}
 		}
 		if oldPos != nil {
-			db.reclaimableSize += int64(oldPos.Size)
+			db.discardPos(ns, oldPos)
 		}
 	}
 
-	// 暂存事务数据
-	transactionRecords := make(map[uint64][]*data.TransactionRecord)
-	var currentTransactionId uint64 = nonTransactionSeqId
+	// 暂存事务数据，只读模式下继续使用上次读取时尚未完成的事务数据
+	transactionRecords := db.pendingTxnRecords
+	if transactionRecords == nil {
+		transactionRecords = make(map[uint64][]*data.TransactionRecord)
+	}
+	var currentTransactionId = db.seqId
 
-	// 遍历所有的数据文件
-	for _, fid := range fileIds {
-		var fileId = uint32(fid)
-		var file *data.File
-		if fileId == db.activeFile.Id {
-			file = db.activeFile
-		} else {
-			file = db.olderFiles[fileId]
+	// 处理数据文件中的一条记录，非事务记录直接更新索引，事务记录在读取到完成标记之后再更新索引
+	var replay = func(record *data.LogRecord, pos *data.LogRecordPos) error {
+		if pos.Fid == db.activeFile.Id && db.dataHintEnabled() {
+			// 重建活跃文件的索引信息，写满或关闭时生成完整的 hint 文件
+			if err := db.appendDataHint(record, pos); err != nil {
+				return err
+			}
 		}
 
-		var offset int64 = 0
-		for {
-			record, size, err := file.ReadLogRecord(offset)
-			if err != nil {
-				if errors.Is(err, io.EOF) {
-					break
+		// 解析 key, 拿到事务序列号
+		realKey, seqId := parsedLogRecordKey(record.Key)
+		if seqId == nonTransactionSeqId {
+			// 非事务记录，直接更新索引
+			updateIndex(record, realKey, pos)
+		} else {
+			if record.Type == data.LogRecordTypeTransactionFinished {
+				for _, r := range transactionRecords[seqId] {
+					updateIndex(r.Record, r.Record.Key, r.Pos)
 				}
-				return err
+				delete(transactionRecords, seqId)
+			} else {
+				record.Key = realKey
+				transactionRecords[seqId] = append(transactionRecords[seqId], &data.TransactionRecord{
+					Record: record,
+					Pos:    pos,
+				})
 			}
+		}
 
-			// 构造内存索引信息
-			pos := &data.LogRecordPos{
-				Fid:    file.Id,
-				Offset: offset,
-				Size:   uint32(size),
-			}
+		// 更新事务序列号
+		if seqId > currentTransactionId {
+			currentTransactionId = seqId
+		}
+		return nil
+	}
 
-			// 解析 key, 拿到事务序列号
-			realKey, seqId := parsedLogRecordKey(record.Key)
-			if seqId == nonTransactionSeqId {
-				// 非事务记录，直接更新索引
-				updateIndex(realKey, record.Type, pos)
-			} else {
-				if record.Type == data.LogRecordTypeTransactionFinished {
-					for _, r := range transactionRecords[seqId] {
-						updateIndex(r.Record.Key, r.Record.Type, r.Pos)
-					}
-					delete(transactionRecords, seqId)
-				} else {
-					record.Key = realKey
-					transactionRecords[seqId] = append(transactionRecords[seqId], &data.TransactionRecord{
-						Record: record,
-						Pos:    pos,
-					})
-				}
+	// 并发读取所有的数据文件，按照文件 id 的顺序依次更新索引，保证后写入的数据覆盖先写入的数据
+	var files = make([]*data.File, 0, len(fileIds))
+	for _, fid := range fileIds {
+		var fileId = uint32(fid)
+		if fileId == db.activeFile.Id {
+			files = append(files, db.activeFile)
+		} else {
+			files = append(files, db.olderFiles[fileId])
+		}
+	}
+	err := db.readDataFiles(files, func(loaded *loadedFile) error {
+		for _, event := range loaded.events {
+			if err := db.applyRecovery(event); err != nil {
+				return err
 			}
-
-			// 更新事务序列号
-			if seqId > currentTransactionId {
-				currentTransactionId = seqId
+		}
+		for _, r := range loaded.records {
+			if err := replay(r.record, r.pos); err != nil {
+				return err
 			}
-
-			//
-			offset += size
 		}
-
 		// 如果是当前活跃文件，更新这个文件的 writeOffset
-		if fileId == db.activeFile.Id {
-			db.activeFile.WriteOffset = offset
+		if loaded.file == db.activeFile {
+			db.activeFile.WriteOffset = loaded.offset
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	// 更新当前事务序列号
 	db.seqId = currentTransactionId
+	if db.options.ReadOnly {
+		db.pendingTxnRecords = transactionRecords
+	}
 	return nil
 }
 
@@ -655,5 +1158,47 @@ func checkOptions(options *Options) error {
 	if options.DataFileMergeThreshold < 0 || options.DataFileMergeThreshold > 1 {
 		return errors.New("database data file merge threshold must be between 0 and 1")
 	}
+	if options.ReadOnly && options.IndexType == BPlusTree {
+		return errors.New("database read only mode is not supported when index type is BPlusTree")
+	}
+	if options.KeyProvider == nil && len(options.EncryptionKey) > 0 {
+		if _, err := aes.NewCipher(options.EncryptionKey); err != nil {
+			return fmt.Errorf("database encryption key is invalid: %w", err)
+		}
+	}
+	if (options.KeyProvider != nil || len(options.EncryptionKey) > 0) && options.IndexType == BPlusTree {
+		// B+ 树索引文件中保存的是明文 key
+		return errors.New("database encryption is not supported when index type is BPlusTree")
+	}
+	if options.Compressor == nil && options.Compression > CompressionGzip {
+		return errors.New("database compression type is unknown")
+	}
+	if options.Compressor != nil && options.Compressor.Type() <= CompressionGzip {
+		return errors.New("database custom compressor type must be greater than CompressionGzip")
+	}
+	if options.RecoveryMode < RecoveryStrict || options.RecoveryMode > RecoverySkipCorrupt {
+		return errors.New("database recovery mode is unknown")
+	}
+	if options.LoadConcurrency < 0 {
+		return errors.New("database load concurrency must not be negative")
+	}
+	if options.LargeValueThreshold < 0 {
+		return errors.New("database large value threshold must not be negative")
+	}
+	if options.LargeValueThreshold > 0 && options.IndexType == BPlusTree {
+		return errors.New("database large value threshold is not supported when index type is BPlusTree")
+	}
+	if options.BlobGCThreshold < 0 || options.BlobGCThreshold > 1 {
+		return errors.New("database blob gc threshold must be between 0 and 1")
+	}
+	if options.BlobGCInterval < 0 {
+		return errors.New("database blob gc interval must not be negative")
+	}
+	if options.AutoMergeInterval < 0 {
+		return errors.New("database auto merge interval must not be negative")
+	}
+	if _, err := parseMergeWindow(options.AutoMergeWindow); err != nil {
+		return err
+	}
 	return nil
 }
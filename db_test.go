@@ -1,6 +1,8 @@
 package bitcask_go
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/xiecang/bitcask/data"
 	"github.com/xiecang/bitcask/utils"
@@ -10,6 +12,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 var indexTypesForTest = []IndexType{BTree, BPlusTree, ART}
@@ -1121,3 +1124,209 @@ func TestOpen(t *testing.T) {
 	}
 
 }
+
+func TestDB_PutWithTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		sleep   time.Duration
+		wantErr error
+	}{
+		{
+			name:    "not expired",
+			ttl:     time.Hour,
+			wantErr: nil,
+		},
+		{
+			name:    "expired",
+			ttl:     time.Millisecond,
+			sleep:   5 * time.Millisecond,
+			wantErr: ErrKeyNotFound,
+		},
+	}
+	for _, tt := range tests {
+		for _, indexType := range indexTypesForTest {
+			name := fmt.Sprintf("%s-indexTYpe_%s", tt.name, indexTypeString(indexType))
+			options := defaultOptions()
+			options.IndexType = indexType
+
+			t.Run(name, func(t *testing.T) {
+				db, err := Open(options)
+				if err != nil {
+					t.Errorf("Open() error = %v", err)
+					return
+				}
+				defer func() { destroyDB(db) }()
+				if err = db.PutWithTTL([]byte("key"), []byte("value"), tt.ttl); err != nil {
+					t.Errorf("PutWithTTL() error = %v", err)
+					return
+				}
+				if err = db.Put([]byte("key2"), []byte("value2")); err != nil {
+					t.Errorf("Put() error = %v", err)
+					return
+				}
+				time.Sleep(tt.sleep)
+
+				if _, err = db.Get([]byte("key")); !errors.Is(err, tt.wantErr) {
+					t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				var wantKeys = 2
+				if tt.wantErr != nil {
+					wantKeys = 1
+				}
+				if got := len(db.ListKeys()); got != wantKeys {
+					t.Errorf("ListKeys() len = %v, want %v", got, wantKeys)
+				}
+				var folded int
+				if err = db.Fold(func(key, value []byte) bool {
+					folded++
+					return true
+				}); err != nil {
+					t.Errorf("Fold() error = %v", err)
+				}
+				if folded != wantKeys {
+					t.Errorf("Fold() count = %v, want %v", folded, wantKeys)
+				}
+				iterator := db.NewIterator(defaultIteratorOption())
+				var iterated int
+				for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+					iterated++
+				}
+				iterator.Close()
+				if iterated != wantKeys {
+					t.Errorf("Iterator count = %v, want %v", iterated, wantKeys)
+				}
+
+				// 重启后过期时间依然有效
+				if err = db.Close(); err != nil {
+					t.Errorf("Close() error = %v", err)
+					return
+				}
+				db, err = Open(options)
+				if err != nil {
+					t.Errorf("Open() error = %v", err)
+					return
+				}
+				if _, err = db.Get([]byte("key")); !errors.Is(err, tt.wantErr) {
+					t.Errorf("Get() after reopen error = %v, wantErr %v", err, tt.wantErr)
+				}
+			})
+		}
+	}
+
+	t.Run("invalid ttl", func(t *testing.T) {
+		db, err := Open(defaultOptions())
+		if err != nil {
+			t.Errorf("Open() error = %v", err)
+			return
+		}
+		defer func() { destroyDB(db) }()
+		if err = db.PutWithTTL([]byte("key"), []byte("value"), 0); !errors.Is(err, ErrInvalidTTL) {
+			t.Errorf("PutWithTTL() error = %v, wantErr %v", err, ErrInvalidTTL)
+		}
+	})
+}
+
+func TestDB_Expire(t *testing.T) {
+	db, err := Open(defaultOptions())
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer destroyDB(db)
+
+	if err = db.Expire([]byte("not-exist"), time.Hour); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expire() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+	if _, err = db.TTL([]byte("not-exist")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("TTL() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+
+	if err = db.Put([]byte("key"), []byte("value")); err != nil {
+		t.Errorf("Put() error = %v", err)
+		return
+	}
+	if ttl, err := db.TTL([]byte("key")); err != nil || ttl != -1 {
+		t.Errorf("TTL() = %v, error = %v, want -1", ttl, err)
+	}
+
+	if err = db.Expire([]byte("key"), time.Hour); err != nil {
+		t.Errorf("Expire() error = %v", err)
+	}
+	if ttl, err := db.TTL([]byte("key")); err != nil || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL() = %v, error = %v", ttl, err)
+	}
+	if value, err := db.Get([]byte("key")); err != nil || string(value) != "value" {
+		t.Errorf("Get() = %s, error = %v", value, err)
+	}
+
+	if err = db.Persist([]byte("key")); err != nil {
+		t.Errorf("Persist() error = %v", err)
+	}
+	if ttl, err := db.TTL([]byte("key")); err != nil || ttl != -1 {
+		t.Errorf("TTL() after persist = %v, error = %v, want -1", ttl, err)
+	}
+
+	if err = db.Expire([]byte("key"), time.Millisecond); err != nil {
+		t.Errorf("Expire() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err = db.Get([]byte("key")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+	if err = db.Persist([]byte("key")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Persist() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestDB_FoldContext(t *testing.T) {
+	options := defaultOptions()
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+	for i := 0; i < 10; i++ {
+		_ = db.Put([]byte(fmt.Sprintf("key-%d", i)), []byte("value"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var visited int
+	err = db.FoldContext(ctx, func(key, value []byte) bool {
+		if visited++; visited == 3 {
+			cancel()
+		}
+		return true
+	})
+	if !errors.Is(err, context.Canceled) || visited != 3 {
+		t.Errorf("FoldContext() error = %v, visited = %d", err, visited)
+	}
+}
+
+func TestDB_BackupContext(t *testing.T) {
+	options := defaultOptions()
+	options.MaxFileSize = 512
+	var dir = options.DirPath + "-backup"
+	defer func() { _ = os.RemoveAll(dir) }()
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+	for i := 0; i < 100; i++ {
+		_ = db.Put([]byte(fmt.Sprintf("key-%d", i)), []byte("value"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = db.BackupContext(ctx, dir); !errors.Is(err, context.Canceled) {
+		t.Errorf("BackupContext() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err = os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("BackupContext() left the backup directory")
+	}
+	if err = db.BackupContext(context.Background(), dir); err != nil {
+		t.Errorf("BackupContext() error = %v", err)
+	}
+}
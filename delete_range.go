@@ -0,0 +1,117 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"github.com/xiecang/bitcask/data"
+)
+
+// DeleteRange 删除 [start, end) 范围内的所有 key，start 为空时从最小的 key 开始，end 为空时不限制上界
+// 只写入一条范围删除记录，并在同一个临界区内从内存索引中删除范围内的所有 key
+func (db *DB) DeleteRange(start, end []byte) error {
+	return db.deleteRange(nil, start, end)
+}
+
+// DeletePrefix 删除所有以 prefix 为前缀的 key
+func (db *DB) DeletePrefix(prefix []byte) error {
+	if len(prefix) == 0 {
+		return ErrKeyIsEmpty
+	}
+	return db.deleteRange(nil, prefix, prefixEnd(prefix))
+}
+
+// DeleteRange 删除命名空间中 [start, end) 范围内的所有 key
+func (ns *Namespace) DeleteRange(start, end []byte) error {
+	return ns.db.deleteRange(ns, start, end)
+}
+
+// DeletePrefix 删除命名空间中所有以 prefix 为前缀的 key
+func (ns *Namespace) DeletePrefix(prefix []byte) error {
+	if len(prefix) == 0 {
+		return ErrKeyIsEmpty
+	}
+	return ns.db.deleteRange(ns, prefix, prefixEnd(prefix))
+}
+
+// deleteRange 删除命名空间 ns 中 [start, end) 范围内的所有 key，ns 为 nil 时表示默认命名空间
+func (db *DB) deleteRange(ns *Namespace, start, end []byte) error {
+	if len(end) > 0 && bytes.Compare(start, end) >= 0 {
+		return ErrInvalidKeyRange
+	}
+	if db.options.ReadOnly {
+		return ErrReadOnly
+	}
+
+	record := data.LogRecord{
+		Key:       logRecordKeyWithSeq(start, nonTransactionSeqId),
+		Value:     end,
+		Type:      data.LogRecordTypeRangeDelete,
+		Namespace: namespaceName(ns),
+	}
+	return db.appendLogRecordWithLock(&record, func(pos *data.LogRecordPos) error {
+		db.applyDeleteRange(ns, start, end, pos)
+		return nil
+	})
+}
+
+// applyDeleteRange 写入范围删除记录之后从命名空间 ns 的内存索引中删除范围内的 key，需要持有 db.mu
+func (db *DB) applyDeleteRange(ns *Namespace, start, end []byte, pos *data.LogRecordPos) {
+	// 范围删除记录本身也是可以回收的
+	db.discardPos(ns, pos)
+
+	keys := db.deleteIndexRange(ns, start, end)
+	if len(keys) == 0 {
+		return
+	}
+	seqId := db.nonTxnWriteSeqId()
+	if ns == nil {
+		db.trackWrites(seqId, keys...)
+		db.observeIndexSize()
+	}
+	if len(db.watchers) > 0 {
+		var events = make([]WatchEvent, 0, len(keys))
+		for _, key := range keys {
+			events = append(events, WatchEvent{
+				Type:      WatchEventDelete,
+				Key:       key,
+				SeqId:     seqId,
+				Namespace: namespaceName(ns),
+			})
+		}
+		db.notifyWatchers(events)
+	}
+}
+
+// deleteIndexRange 从命名空间 ns 的内存索引中删除 [start, end) 范围内的所有 key，返回被删除的 key，需要持有 db.mu
+func (db *DB) deleteIndexRange(ns *Namespace, start, end []byte) [][]byte {
+	indexer := db.indexOf(ns)
+	iterator := indexer.Iterator(false)
+	var keys [][]byte
+	for iterator.Seek(start); iterator.Valid(); iterator.Next() {
+		key := iterator.Key()
+		if len(end) > 0 && bytes.Compare(key, end) >= 0 {
+			break
+		}
+		// B+ 树迭代器返回的 key 只在迭代器关闭之前有效
+		keys = append(keys, append([]byte(nil), key...))
+	}
+	iterator.Close()
+
+	for _, key := range keys {
+		if oldPos, _ := indexer.Delete(key); oldPos != nil {
+			db.discardPos(ns, oldPos)
+		}
+	}
+	return keys
+}
+
+// prefixEnd 返回大于所有以 prefix 为前缀的 key 的最小值，prefix 全部为 0xff 时返回 nil，表示不限制上界
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDB_DeleteRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		delete    func(db *DB) error
+		wantCount int
+		wantGone  []string
+		wantKept  []string
+	}{
+		{name: "range", delete: func(db *DB) error {
+			return db.DeleteRange([]byte("a/010"), []byte("a/020"))
+		}, wantCount: 50, wantGone: []string{"a/010", "a/019"}, wantKept: []string{"a/009", "a/020", "b/010"}},
+		{name: "unbounded end", delete: func(db *DB) error {
+			return db.DeleteRange([]byte("a/030"), nil)
+		}, wantCount: 30, wantGone: []string{"a/030", "b/000", "b/019"}, wantKept: []string{"a/029"}},
+		{name: "prefix", delete: func(db *DB) error {
+			return db.DeletePrefix([]byte("a/"))
+		}, wantCount: 20, wantGone: []string{"a/000", "a/039"}, wantKept: []string{"b/000", "b/019"}},
+		{name: "prefix without match", delete: func(db *DB) error {
+			return db.DeletePrefix([]byte("c/"))
+		}, wantCount: 60, wantKept: []string{"a/000", "b/019"}},
+	}
+	for _, tt := range tests {
+		for _, indexType := range indexTypesForTest {
+			t.Run(fmt.Sprintf("%s-%s", tt.name, indexTypeString(indexType)), func(t *testing.T) {
+				options := defaultOptions()
+				options.IndexType = indexType
+				options.MaxFileSize = 1024
+				options.DataFileMergeThreshold = 0
+				db, err := Open(options)
+				if err != nil {
+					t.Fatalf("Open() error = %v", err)
+				}
+				defer func() {
+					destroyDB(db)
+					_ = os.RemoveAll(db.getMergePath())
+				}()
+				for i := 0; i < 40; i++ {
+					_ = db.Put([]byte(fmt.Sprintf("a/%03d", i)), []byte(fmt.Sprintf("value-%d", i)))
+				}
+				for i := 0; i < 20; i++ {
+					_ = db.Put([]byte(fmt.Sprintf("b/%03d", i)), []byte(fmt.Sprintf("value-%d", i)))
+				}
+				if err = tt.delete(db); err != nil {
+					t.Fatalf("delete error = %v", err)
+				}
+				// 范围删除之后重新写入的 key 不受影响
+				var rewritten []byte
+				if len(tt.wantGone) > 0 {
+					rewritten = []byte(tt.wantGone[0])
+					_ = db.Put(rewritten, []byte("rewritten"))
+				}
+
+				var check = func(stage string) {
+					var count = len(db.ListKeys())
+					if rewritten != nil {
+						count--
+					}
+					if count != tt.wantCount {
+						t.Errorf("%s: got %d keys, want %d", stage, count, tt.wantCount)
+					}
+					for _, key := range tt.wantGone {
+						if key == string(rewritten) {
+							continue
+						}
+						if _, err := db.Get([]byte(key)); err != ErrKeyNotFound {
+							t.Errorf("%s: Get(%s) error = %v, want %v", stage, key, err, ErrKeyNotFound)
+						}
+					}
+					for _, key := range tt.wantKept {
+						if _, err := db.Get([]byte(key)); err != nil {
+							t.Errorf("%s: Get(%s) error = %v", stage, key, err)
+						}
+					}
+					if rewritten != nil {
+						if value, _ := db.Get(rewritten); !bytes.Equal(value, []byte("rewritten")) {
+							t.Errorf("%s: Get(%s) = %s", stage, rewritten, value)
+						}
+					}
+				}
+				check("after delete")
+
+				// 从 hint 文件重建索引
+				_ = db.Close()
+				if db, err = Open(options); err != nil {
+					t.Fatalf("Open() error = %v", err)
+				}
+				check("reopen")
+
+				// 只从数据文件重建索引
+				_ = db.Close()
+				_ = removeDataHints(options.DirPath, 1<<16)
+				if db, err = Open(options); err != nil {
+					t.Fatalf("Open() error = %v", err)
+				}
+				check("reopen without hint files")
+
+				if indexType == BPlusTree {
+					// B+ 树索引持久化在磁盘上，merge 之后不会根据 hint 文件重建
+					return
+				}
+				if err = db.Merge(); err != nil {
+					t.Fatalf("Merge() error = %v", err)
+				}
+				_ = db.Close()
+				if db, err = Open(options); err != nil {
+					t.Fatalf("Open() error = %v", err)
+				}
+				check("after merge")
+			})
+		}
+	}
+}
+
+func TestDB_DeleteRange_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		delete  func(db *DB) error
+		wantErr error
+	}{
+		{name: "start equals end", delete: func(db *DB) error {
+			return db.DeleteRange([]byte("b"), []byte("b"))
+		}, wantErr: ErrInvalidKeyRange},
+		{name: "start after end", delete: func(db *DB) error {
+			return db.DeleteRange([]byte("c"), []byte("b"))
+		}, wantErr: ErrInvalidKeyRange},
+		{name: "empty prefix", delete: func(db *DB) error {
+			return db.DeletePrefix(nil)
+		}, wantErr: ErrKeyIsEmpty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Open(defaultOptions())
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer destroyDB(db)
+			if err = tt.delete(db); err != tt.wantErr {
+				t.Errorf("delete error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNamespace_DeletePrefix(t *testing.T) {
+	options := defaultOptions()
+	options.EncryptionKey = bytes.Repeat([]byte("k"), 16)
+	options.Compression = CompressionGzip
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { destroyDB(db) }()
+	ns, _ := db.Namespace("tenant")
+	for i := 0; i < 10; i++ {
+		_ = db.Put([]byte(fmt.Sprintf("user/%d", i)), []byte("value"))
+		_ = ns.Put([]byte(fmt.Sprintf("user/%d", i)), []byte("value"))
+	}
+	// 上界较长时同样不会被压缩
+	if err = ns.DeleteRange([]byte("user/"), bytes.Repeat([]byte("user/9"), 100)); err != nil {
+		t.Fatalf("DeleteRange() error = %v", err)
+	}
+	if err = ns.DeletePrefix([]byte("user/")); err != nil {
+		t.Fatalf("DeletePrefix() error = %v", err)
+	}
+	_ = db.Close()
+	if db, err = Open(options); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	ns, _ = db.Namespace("tenant")
+	if stat := ns.Stat(); stat.KeyNum != 0 {
+		t.Errorf("namespace Stat() = %+v", stat)
+	}
+	if got := len(db.ListKeys()); got != 10 {
+		t.Errorf("default namespace has %d keys, want 10", got)
+	}
+}
+
+func Test_prefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix []byte
+		want   []byte
+	}{
+		{prefix: []byte("a/"), want: []byte("a0")},
+		{prefix: []byte{'a', 0xff}, want: []byte("b")},
+		{prefix: []byte{0xff, 0xff}, want: nil},
+	}
+	for _, tt := range tests {
+		if got := prefixEnd(tt.prefix); !bytes.Equal(got, tt.want) {
+			t.Errorf("prefixEnd(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package bitcask_go
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/xiecang/bitcask/data"
+	"io"
+	"sort"
+	"time"
+)
+
+// 逻辑导出的数据格式，两种格式都按照命名空间依次导出，默认命名空间在最前面，同一个命名空间内 key 从小到大排列
+//
+// DumpJSONLines: 每行一个 JSON 对象，[]byte 类型的字段使用标准 base64 编码
+//
+//	{"ns":"<base64，默认命名空间省略>","key":"<base64>","value":"<base64>","expire":<过期时间 unix 纳秒，不过期时省略>}
+//
+// DumpBinary: 以 dumpBinaryMagic 开头，之后每条记录依次为
+//
+//	uvarint(len(ns)) | ns | uvarint(len(key)) | key | uvarint(len(value)) | value | varint(expire)
+var dumpBinaryMagic = []byte("bitcask-dump-v1\n")
+
+// maxDumpFieldSize 二进制格式中单个字段的最大长度
+const maxDumpFieldSize = 1 << 32
+
+// dumpReadChunkSize 读取二进制格式的字段时一次分配的最大内存，较长的字段随着读取到的数据增长，损坏的长度不会导致分配过多的内存
+const dumpReadChunkSize = 64 * 1024
+
+// dumpRecord 导出数据中的一条记录
+type dumpRecord struct {
+	Namespace []byte `json:"ns,omitempty"`
+	Key       []byte `json:"key"`
+	Value     []byte `json:"value"`
+	Expire    int64  `json:"expire,omitempty"`
+}
+
+// Export 按照 options 中的格式导出所有命名空间中满足过滤条件且没有过期的 key-value
+// 每个命名空间遍历的是开始导出该命名空间时的索引，每次读取 value 时才持有读锁，导出过程中不会阻塞写入
+func (db *DB) Export(w io.Writer, options ExportOption) error {
+	bw := bufio.NewWriter(w)
+	var write func(record *dumpRecord) error
+	switch options.Format {
+	case DumpJSONLines:
+		encoder := json.NewEncoder(bw)
+		write = func(record *dumpRecord) error { return encoder.Encode(record) }
+	case DumpBinary:
+		if _, err := bw.Write(dumpBinaryMagic); err != nil {
+			return err
+		}
+		write = func(record *dumpRecord) error { return writeBinaryDumpRecord(bw, record) }
+	default:
+		return ErrUnknownDumpFormat
+	}
+
+	db.mu.RLock()
+	var names = make([]string, 0, len(db.namespaces))
+	for name := range db.namespaces {
+		names = append(names, name)
+	}
+	db.mu.RUnlock()
+	sort.Strings(names)
+
+	var namespaces = []*Namespace{nil}
+	for _, name := range names {
+		db.mu.RLock()
+		namespaces = append(namespaces, db.namespaces[name])
+		db.mu.RUnlock()
+	}
+	for _, ns := range namespaces {
+		if err := db.exportNamespace(ns, options, write); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// exportNamespace 导出一个命名空间中满足过滤条件的数据
+func (db *DB) exportNamespace(ns *Namespace, options ExportOption, write func(record *dumpRecord) error) error {
+	db.mu.RLock()
+	iterator := db.indexOf(ns).Iterator(false)
+	db.mu.RUnlock()
+	defer iterator.Close()
+
+	var seek = options.Start
+	if bytes.Compare(options.Prefix, seek) > 0 {
+		seek = options.Prefix
+	}
+	var now = time.Now().UnixNano()
+	for iterator.Seek(seek); iterator.Valid(); iterator.Next() {
+		key, pos := iterator.Key(), iterator.Value()
+		if len(options.End) > 0 && bytes.Compare(key, options.End) >= 0 {
+			break
+		}
+		if !bytes.HasPrefix(key, options.Prefix) {
+			break
+		}
+		if pos.IsExpired(now) {
+			continue
+		}
+		db.mu.RLock()
+		value, err := db.getValueByPosition(pos)
+		db.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+		if err = write(&dumpRecord{
+			Namespace: namespaceName(ns),
+			Key:       key,
+			Value:     value,
+			Expire:    pos.Expire,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import 读取 Export 导出的数据，每 BatchSize 条数据使用一个 WriteBatch 原子地写入
+// 导出之后已经过期的数据会被跳过，导入完成之后持久化数据文件
+func (db *DB) Import(r io.Reader, options ImportOption) error {
+	if db.options.ReadOnly {
+		return ErrReadOnly
+	}
+	br := bufio.NewReader(r)
+	var read func() (*dumpRecord, error)
+	switch options.Format {
+	case DumpJSONLines:
+		decoder := json.NewDecoder(br)
+		read = func() (*dumpRecord, error) {
+			var record dumpRecord
+			err := decoder.Decode(&record)
+			return &record, err
+		}
+	case DumpBinary:
+		magic := make([]byte, len(dumpBinaryMagic))
+		if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, dumpBinaryMagic) {
+			return ErrInvalidDump
+		}
+		read = func() (*dumpRecord, error) { return readBinaryDumpRecord(br) }
+	default:
+		return ErrUnknownDumpFormat
+	}
+
+	var batchSize = options.BatchSize
+	if batchSize == 0 {
+		batchSize = DefaultImportOptions.BatchSize
+	}
+	var imported uint64
+	var pending uint
+	wb := db.NewWriteBatch(WriteBatchOption{MaxBatchSize: batchSize})
+	var commit = func() error {
+		if err := wb.Commit(); err != nil {
+			return err
+		}
+		imported += uint64(pending)
+		pending = 0
+		if options.Progress != nil {
+			options.Progress(imported)
+		}
+		return nil
+	}
+
+	var now = time.Now().UnixNano()
+	for {
+		record, err := read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(record.Key) == 0 {
+			return ErrInvalidDump
+		}
+		if len(record.Namespace) > 0 && db.options.IndexType == BPlusTree {
+			return ErrNamespaceNotSupported
+		}
+		if record.Expire > 0 && record.Expire <= now {
+			continue
+		}
+		wb.putRecord(&data.LogRecord{
+			Key:       record.Key,
+			Value:     record.Value,
+			Type:      data.LogRecordTypeNormal,
+			Expire:    record.Expire,
+			Namespace: record.Namespace,
+		})
+		if pending++; pending >= batchSize {
+			if err = commit(); err != nil {
+				return err
+			}
+		}
+	}
+	if pending > 0 {
+		if err := commit(); err != nil {
+			return err
+		}
+	}
+	return db.Sync()
+}
+
+func writeBinaryDumpRecord(w *bufio.Writer, record *dumpRecord) error {
+	var buf = make([]byte, 0, binary.MaxVarintLen64*4+len(record.Namespace)+len(record.Key)+len(record.Value))
+	for _, field := range [][]byte{record.Namespace, record.Key, record.Value} {
+		buf = binary.AppendUvarint(buf, uint64(len(field)))
+		buf = append(buf, field...)
+	}
+	buf = binary.AppendVarint(buf, record.Expire)
+	_, err := w.Write(buf)
+	return err
+}
+
+// readBinaryDumpRecord 读取一条二进制格式的记录，数据正好结束时返回 io.EOF
+func readBinaryDumpRecord(r *bufio.Reader) (*dumpRecord, error) {
+	if _, err := r.Peek(1); err != nil {
+		return nil, err
+	}
+	var fields [3][]byte
+	for i := range fields {
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if size > maxDumpFieldSize {
+			return nil, ErrInvalidDump
+		}
+		if fields[i], err = readDumpField(r, int64(size)); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+	expire, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return &dumpRecord{Namespace: fields[0], Key: fields[1], Value: fields[2], Expire: expire}, nil
+}
+
+// readDumpField 读取长度为 size 的字段，超过 dumpReadChunkSize 时按照实际读取到的数据扩容
+func readDumpField(r io.Reader, size int64) ([]byte, error) {
+	if size <= dumpReadChunkSize {
+		field := make([]byte, size)
+		_, err := io.ReadFull(r, field)
+		return field, err
+	}
+	var buf bytes.Buffer
+	buf.Grow(dumpReadChunkSize)
+	if _, err := io.CopyN(&buf, r, size); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unexpectedEOF 记录中间遇到的 io.EOF 表示数据不完整
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// dumpContent 返回默认命名空间和命名空间 ns 中的所有数据，以命名空间和 key 作为 map 的 key
+func dumpContent(t *testing.T, db *DB) map[string]string {
+	var content = make(map[string]string)
+	var iterators = map[string]*Iterator{"": db.NewIterator(defaultIteratorOption())}
+	if db.options.IndexType != BPlusTree {
+		ns, _ := db.Namespace("ns")
+		iterators["ns"] = ns.NewIterator(defaultIteratorOption())
+	}
+	for name, iterator := range iterators {
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			value, err := iterator.Value()
+			if err != nil {
+				t.Fatalf("Value() error = %v", err)
+			}
+			content[name+"/"+string(iterator.Key())] = string(value)
+		}
+		iterator.Close()
+	}
+	return content
+}
+
+func TestDB_ExportImport(t *testing.T) {
+	tests := []struct {
+		name      string
+		indexType IndexType
+		export    ExportOption
+		wantKeys  []string // 为空时导出所有数据
+	}{
+		{name: "json lines", indexType: BTree, export: DefaultExportOptions},
+		{name: "binary", indexType: BTree, export: ExportOption{Format: DumpBinary}},
+		{name: "binary art", indexType: ART, export: ExportOption{Format: DumpBinary}},
+		{name: "json lines bptree", indexType: BPlusTree, export: DefaultExportOptions},
+		{name: "prefix", indexType: BTree, export: ExportOption{Format: DumpBinary, Prefix: []byte("key-1")},
+			wantKeys: []string{"/key-1", "/key-10", "/key-11", "ns/key-1"}},
+		{name: "range", indexType: BTree, export: ExportOption{Start: []byte("key-10"), End: []byte("key-2")},
+			wantKeys: []string{"/key-10", "/key-11"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.IndexType = tt.indexType
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer func() { destroyDB(db) }()
+			for i := 0; i < 12; i++ {
+				_ = db.Put([]byte(fmt.Sprintf("key-%d", i)), []byte(fmt.Sprintf("value-%d", i)))
+			}
+			// 二进制 key、空 value 以及带有过期时间的数据
+			_ = db.Put([]byte{0, 0xff, '\n'}, []byte{})
+			_ = db.PutWithTTL([]byte("ttl"), []byte("ttl-value"), time.Hour)
+			_ = db.Delete([]byte("key-0"))
+			if tt.indexType != BPlusTree {
+				ns, _ := db.Namespace("ns")
+				_ = ns.Put([]byte("key-1"), []byte("ns-value"))
+			}
+			want := dumpContent(t, db)
+
+			var buf bytes.Buffer
+			if err = db.Export(&buf, tt.export); err != nil {
+				t.Errorf("Export() error = %v", err)
+				return
+			}
+			_ = db.Close()
+			_ = os.RemoveAll(options.DirPath)
+
+			if db, err = Open(options); err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			var progress []uint64
+			if err = db.Import(&buf, ImportOption{
+				Format:    tt.export.Format,
+				BatchSize: 5,
+				Progress: func(imported uint64) {
+					progress = append(progress, imported)
+				},
+			}); err != nil {
+				t.Errorf("Import() error = %v", err)
+				return
+			}
+
+			got := dumpContent(t, db)
+			if tt.wantKeys != nil {
+				var filtered = make(map[string]string)
+				for _, k := range tt.wantKeys {
+					filtered[k] = want[k]
+				}
+				want = filtered
+			}
+			if len(got) != len(want) {
+				t.Errorf("Import() got %d keys, want %d", len(got), len(want))
+			}
+			for k, v := range want {
+				if got[k] != v {
+					t.Errorf("Import() %q = %q, want %q", k, got[k], v)
+				}
+			}
+			if len(progress) == 0 || progress[len(progress)-1] != uint64(len(want)) {
+				t.Errorf("Import() progress = %v", progress)
+			}
+			if tt.wantKeys == nil {
+				if ttl, err := db.TTL([]byte("ttl")); err != nil || ttl <= 0 || ttl > time.Hour {
+					t.Errorf("TTL(ttl) = %v, error = %v", ttl, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDB_Import_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  DumpFormat
+		input   string
+		wantErr error
+	}{
+		{name: "unknown format", format: 10, wantErr: ErrUnknownDumpFormat},
+		{name: "binary without magic", format: DumpBinary, input: "{}", wantErr: ErrInvalidDump},
+		{name: "empty key", format: DumpJSONLines, input: `{"value":"dmFsdWU="}`, wantErr: ErrInvalidDump},
+		{name: "field size too large", format: DumpBinary,
+			input: string(dumpBinaryMagic) + "\x00" + string(binary.AppendUvarint(nil, maxDumpFieldSize+1)), wantErr: ErrInvalidDump},
+		// 长度损坏时只分配实际读取到的数据
+		{name: "truncated large field", format: DumpBinary,
+			input: string(dumpBinaryMagic) + "\x00" + string(binary.AppendUvarint(nil, maxDumpFieldSize)) + "key", wantErr: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer destroyDB(db)
+			if err = db.Import(bytes.NewReader([]byte(tt.input)), ImportOption{Format: tt.format}); err != tt.wantErr {
+				t.Errorf("Import() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_readDumpField(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "empty", size: 0},
+		{name: "small", size: 16},
+		{name: "larger than chunk", size: dumpReadChunkSize*2 + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := bytes.Repeat([]byte("v"), tt.size)
+			got, err := readDumpField(bytes.NewReader(append(want, "rest"...)), int64(tt.size))
+			if err != nil || !bytes.Equal(got, want) {
+				t.Errorf("readDumpField() = %d bytes, error = %v", len(got), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package bitcask_go
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"github.com/xiecang/bitcask/data"
+	"sync"
+)
+
+// KeyProvider 加密密钥的提供者，支持密钥轮换
+// 写入时使用当前的密钥，读取时根据记录中的密钥 id 获取对应的密钥，旧密钥加密的数据在 Merge 时使用当前的密钥重新加密
+type KeyProvider interface {
+	// CurrentKey 返回写入时使用的密钥及其 id，id 必须大于 0，密钥长度为 16、24 或 32 字节
+	CurrentKey() (uint32, []byte, error)
+	// Key 根据 id 返回对应的密钥
+	Key(id uint32) ([]byte, error)
+}
+
+// staticKeyProvider 使用 Options.EncryptionKey 作为唯一的密钥
+type staticKeyProvider struct {
+	key []byte
+}
+
+const staticKeyId = 1
+
+func (p staticKeyProvider) CurrentKey() (uint32, []byte, error) {
+	return staticKeyId, p.key, nil
+}
+
+func (p staticKeyProvider) Key(id uint32) ([]byte, error) {
+	if id != staticKeyId {
+		return nil, ErrEncryptionKeyNotFound
+	}
+	return p.key, nil
+}
+
+// encryptor 使用 AES-GCM 加密和解密记录中的 key 和 value
+type encryptor struct {
+	provider KeyProvider
+	mu       *sync.RWMutex
+	aeads    map[uint32]cipher.AEAD // 根据密钥 id 缓存的加密算法实例
+}
+
+// newEncryptor 根据配置创建 encryptor，未配置密钥时返回 nil
+func newEncryptor(options Options) *encryptor {
+	var provider = options.KeyProvider
+	if provider == nil && len(options.EncryptionKey) > 0 {
+		provider = staticKeyProvider{key: options.EncryptionKey}
+	}
+	if provider == nil {
+		return nil
+	}
+	return &encryptor{
+		provider: provider,
+		mu:       &sync.RWMutex{},
+		aeads:    make(map[uint32]cipher.AEAD),
+	}
+}
+
+// aead 返回密钥 id 对应的加密算法实例
+func (e *encryptor) aead(id uint32, key []byte) (cipher.AEAD, error) {
+	e.mu.RLock()
+	aead, ok := e.aeads[id]
+	e.mu.RUnlock()
+	if ok {
+		return aead, nil
+	}
+
+	if key == nil {
+		var err error
+		if key, err = e.provider.Key(id); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.aeads[id] = aead
+	e.mu.Unlock()
+	return aead, nil
+}
+
+// encrypt 使用当前的密钥加密记录的 key 和 value，返回新的记录
+// key 和 value 分别加密，加载索引时只需要解密 key
+// 记录头中的字段作为 key 的附加数据，加密之后的 key 和记录头一起作为 value 的附加数据，密文无法被移动到其他记录中
+func (e *encryptor) encrypt(record *data.LogRecord) (*data.LogRecord, error) {
+	id, key, err := e.provider.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := e.aead(id, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var r = *record
+	r.KeyId = id
+	ad := additionalData(&r)
+	if r.Key, err = seal(aead, record.Key, ad); err != nil {
+		return nil, err
+	}
+	if len(record.Value) > 0 {
+		if r.Value, err = seal(aead, record.Value, append(ad, r.Key...)); err != nil {
+			return nil, err
+		}
+	}
+	return &r, nil
+}
+
+// decrypt 解密记录的 key 和 value
+func (e *encryptor) decrypt(record *data.LogRecord) error {
+	aead, err := e.aead(record.KeyId, nil)
+	if err != nil {
+		return err
+	}
+	ad := additionalData(record)
+	key, err := open(aead, record.Key, ad)
+	if err != nil {
+		return err
+	}
+	var value = record.Value
+	if len(value) > 0 {
+		if value, err = open(aead, record.Value, append(ad, record.Key...)); err != nil {
+			return err
+		}
+	}
+	record.Key = key
+	record.Value = value
+	record.KeyId = 0
+	return nil
+}
+
+// additionalData 返回记录头中需要认证的字段：类型、过期时间、命名空间、压缩算法、是否存储在 blob 文件中以及密钥 id
+func additionalData(record *data.LogRecord) []byte {
+	var buf = make([]byte, 0, 2+3*binary.MaxVarintLen64+len(record.Namespace))
+	buf = append(buf, byte(record.Type), record.Codec)
+	if record.ValueInBlob {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+	buf = binary.AppendVarint(buf, record.Expire)
+	buf = binary.AppendUvarint(buf, uint64(record.KeyId))
+	buf = binary.AppendUvarint(buf, uint64(len(record.Namespace)))
+	return append(buf, record.Namespace...)
+}
+
+// seal 加密数据，随机生成的 nonce 存储在密文之前，ad 为需要认证但不加密的附加数据
+func seal(aead cipher.AEAD, plaintext, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+// open 解密 seal 加密的数据
+func open(aead cipher.AEAD, ciphertext, ad []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrDecryptFailed
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[aead.NonceSize():], ad)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+	return plaintext, nil
+}
+
+// encryptRecord 配置了加密密钥时加密记录，返回新的记录
+func (db *DB) encryptRecord(record *data.LogRecord) (*data.LogRecord, error) {
+	if db.encryptor == nil || record.KeyId != 0 {
+		return record, nil
+	}
+	return db.encryptor.encrypt(record)
+}
+
+// decryptRecord 解密记录
+func (db *DB) decryptRecord(record *data.LogRecord) error {
+	if record.KeyId == 0 {
+		return nil
+	}
+	if db.encryptor == nil {
+		return ErrEncryptionKeyNotFound
+	}
+	return db.encryptor.decrypt(record)
+}
@@ -0,0 +1,207 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"errors"
+	"github.com/xiecang/bitcask/data"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testKeyProvider 支持密钥轮换的密钥提供者
+type testKeyProvider struct {
+	current uint32
+	keys    map[uint32][]byte
+}
+
+func (p *testKeyProvider) CurrentKey() (uint32, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+
+func (p *testKeyProvider) Key(id uint32) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, ErrEncryptionKeyNotFound
+	}
+	return key, nil
+}
+
+// dirContains 判断目录下的文件中是否包含指定的内容
+func dirContains(t *testing.T, dir string, content []byte) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), fileLockName) {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if bytes.Contains(b, content) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDB_Encryption(t *testing.T) {
+	options := defaultOptions()
+	options.EncryptionKey = bytes.Repeat([]byte("k"), 32)
+	db, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer func() { destroyDB(db) }()
+
+	_ = db.Put([]byte("secret-key"), []byte("secret-value"))
+	_ = db.Put([]byte("deleted-key"), []byte("secret-value"))
+	_ = db.Delete([]byte("deleted-key"))
+	if err = db.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	for _, content := range []string{"secret-key", "secret-value", "deleted-key"} {
+		if dirContains(t, options.DirPath, []byte(content)) {
+			t.Errorf("data files contain plaintext %s", content)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		key     []byte
+		wantErr error
+	}{
+		{name: "no key", key: nil, wantErr: ErrEncryptionKeyNotFound},
+		{name: "wrong key", key: bytes.Repeat([]byte("x"), 32), wantErr: ErrDecryptFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := options
+			opts.EncryptionKey = tt.key
+			if _, err := Open(opts); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if db, err = Open(options); err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	if got, _ := db.Get([]byte("secret-key")); string(got) != "secret-value" {
+		t.Errorf("Get() = %s, want secret-value", got)
+	}
+	if _, err = db.Get([]byte("deleted-key")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get() deleted key error = %v", err)
+	}
+}
+
+func TestDB_Encryption_rotate(t *testing.T) {
+	provider := &testKeyProvider{
+		current: 1,
+		keys:    map[uint32][]byte{1: bytes.Repeat([]byte("a"), 16)},
+	}
+	options := defaultOptions()
+	options.KeyProvider = provider
+	options.Compression = CompressionFlate
+	db, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer func() { destroyDB(db) }()
+	var value = bytes.Repeat([]byte("value"), 100)
+	_ = db.Put([]byte("old"), value)
+	_ = db.Put([]byte("garbage"), value)
+	_ = db.Delete([]byte("garbage"))
+
+	// 轮换密钥之后，旧密钥加密的数据仍然可以读取
+	provider.keys[2] = bytes.Repeat([]byte("b"), 32)
+	provider.current = 2
+	_ = db.Put([]byte("new"), value)
+	for _, key := range []string{"old", "new"} {
+		if got, _ := db.Get([]byte(key)); !bytes.Equal(got, value) {
+			t.Errorf("Get(%s) value mismatch", key)
+		}
+	}
+
+	// merge 之后所有数据使用新的密钥重新加密，删除旧密钥之后仍然可以读取
+	if err = db.Merge(); err != nil {
+		t.Errorf("Merge() error = %v", err)
+	}
+	_ = db.Close()
+	delete(provider.keys, 1)
+	if db, err = Open(options); err != nil {
+		t.Errorf("Open() after rotation error = %v", err)
+		return
+	}
+	for _, key := range []string{"old", "new"} {
+		if got, _ := db.Get([]byte(key)); !bytes.Equal(got, value) {
+			t.Errorf("Get(%s) value mismatch after merge", key)
+		}
+	}
+}
+
+func TestOpen_invalidEncryptionKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		options func(options *Options)
+	}{
+		{name: "short key", options: func(options *Options) {
+			options.EncryptionKey = []byte("short")
+		}},
+		{name: "bptree index", options: func(options *Options) {
+			options.EncryptionKey = bytes.Repeat([]byte("k"), 16)
+			options.IndexType = BPlusTree
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			tt.options(&options)
+			if db, err := Open(options); err == nil {
+				destroyDB(db)
+				t.Errorf("Open() with invalid options should fail")
+			}
+		})
+	}
+}
+
+func Test_encryptor_additionalData(t *testing.T) {
+	e := newEncryptor(Options{EncryptionKey: bytes.Repeat([]byte("k"), 16)})
+	var encrypt = func(key, value string) *data.LogRecord {
+		record, err := e.encrypt(&data.LogRecord{Key: []byte(key), Value: []byte(value), Type: data.LogRecordTypeNormal})
+		if err != nil {
+			t.Fatalf("encrypt() error = %v", err)
+		}
+		return record
+	}
+	tests := []struct {
+		name   string
+		tamper func(record *data.LogRecord)
+	}{
+		{name: "type", tamper: func(record *data.LogRecord) { record.Type = data.LogRecordTypeDelete }},
+		{name: "expire", tamper: func(record *data.LogRecord) { record.Expire = 1 }},
+		{name: "namespace", tamper: func(record *data.LogRecord) { record.Namespace = []byte("ns") }},
+		{name: "codec", tamper: func(record *data.LogRecord) { record.Codec = 1 }},
+		{name: "swapped value", tamper: func(record *data.LogRecord) { record.Value = encrypt("other", "other").Value }},
+		{name: "swapped key", tamper: func(record *data.LogRecord) { record.Key = encrypt("other", "value").Key }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := encrypt("key", "value")
+			tt.tamper(record)
+			if err := e.decrypt(record); err != ErrDecryptFailed {
+				t.Errorf("decrypt() error = %v, want %v", err, ErrDecryptFailed)
+			}
+		})
+	}
+	if err := e.decrypt(encrypt("key", "value")); err != nil {
+		t.Errorf("decrypt() error = %v", err)
+	}
+}
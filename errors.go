@@ -13,4 +13,25 @@ var (
 	ErrDatabaseIsUsing          = errors.New("the database directory is used by another process")
 	ErrMergeThresholdNotReached = errors.New("the merge threshold does not reach the option")
 	ErrInsufficientDiskSpace    = errors.New("insufficient disk space")
+	ErrInvalidTTL               = errors.New("ttl must be greater than 0")
+	ErrSnapshotReleased         = errors.New("the snapshot has been released")
+	ErrTxnConflict              = errors.New("transaction conflict, the keys read have been modified")
+	ErrTxnFinished              = errors.New("the transaction has been committed or rolled back")
+	ErrValueMismatch            = errors.New("the current value does not match the expected value")
+	ErrValueNotInteger          = errors.New("the value is not an integer")
+	ErrIncrOverflow             = errors.New("increment would overflow")
+	ErrNamespaceIsEmpty         = errors.New("the namespace name is empty")
+	ErrNamespaceNotSupported    = errors.New("namespace is not supported when index type is BPlusTree")
+	ErrReadOnly                 = errors.New("the database is opened in read only mode")
+	ErrUnknownCompression       = errors.New("unknown compression type of the log record")
+	ErrEncryptionKeyNotFound    = errors.New("the encryption key of the log record is not found")
+	ErrDecryptFailed            = errors.New("failed to decrypt the log record, the key is wrong or the data is corrupted")
+	ErrDirNotEmpty              = errors.New("the target directory is not empty")
+	ErrBackupNotSupported       = errors.New("checkpoint and backup are not supported when index type is BPlusTree")
+	ErrBackupChainBroken        = errors.New("the backup chain does not start with a full backup or is not contiguous")
+	ErrInvalidBackupStream      = errors.New("the backup stream is incomplete or does not match its manifest")
+	ErrUnknownDumpFormat        = errors.New("unknown dump format")
+	ErrInvalidDump              = errors.New("the dump data is invalid or uses a different format")
+	ErrInvalidKeyRange          = errors.New("the start key must be less than the end key")
+	ErrReloadWithOpenReaders    = errors.New("cannot reload merged data files while snapshots or iterators are open")
 )
@@ -0,0 +1,125 @@
+package bitcask_go
+
+import (
+	"github.com/xiecang/bitcask/data"
+	"time"
+)
+
+// maxGroupCommitSize 一次组提交最多包含的写入数量
+const maxGroupCommitSize = 1024
+
+// writeRequest 等待组提交的写入
+type writeRequest struct {
+	record *data.LogRecord
+	apply  func(pos *data.LogRecordPos) error
+	err    error
+	done   bool
+}
+
+// groupCommit 将写入加入队列，队首的写入方作为 leader 写入队列中的一组数据，只执行一次 sync
+// 所有写入方都在自己的数据持久化并更新索引之后才返回
+func (db *DB) groupCommit(record *data.LogRecord, apply func(pos *data.LogRecordPos) error) error {
+	req := &writeRequest{record: record, apply: apply}
+
+	db.writeMu.Lock()
+	db.writeQueue = append(db.writeQueue, req)
+	for !req.done && db.writeQueue[0] != req {
+		db.writeCond.Wait()
+	}
+	if req.done {
+		// 已经被其他 leader 提交
+		db.writeMu.Unlock()
+		return req.err
+	}
+
+	// 成为 leader，取出当前排队的一组写入
+	var group = db.writeQueue
+	if len(group) > maxGroupCommitSize {
+		group = group[:maxGroupCommitSize]
+	}
+	group = append([]*writeRequest(nil), group...)
+	db.writeMu.Unlock()
+
+	db.commitGroup(group)
+
+	db.writeMu.Lock()
+	db.writeQueue = db.writeQueue[len(group):]
+	for _, r := range group {
+		r.done = true
+	}
+	// 唤醒本组的写入方，以及下一组的 leader
+	db.writeCond.Broadcast()
+	db.writeMu.Unlock()
+	return req.err
+}
+
+// commitGroup 写入一组数据并执行一次 sync，持久化成功之后依次更新内存索引
+// sync 期间释放 db.mu，读取不会等待 sync，其他修改数据的写入方通过 lockForWrite 等待本组更新索引之后再执行，
+// 保证内存索引按照数据在文件中的顺序更新，旧的位置不会覆盖新的位置
+func (db *DB) commitGroup(group []*writeRequest) {
+	db.lockForWrite()
+	defer db.mu.Unlock()
+
+	var positions = make([]*data.LogRecordPos, len(group))
+	var written bool
+	for i, r := range group {
+		pos, err := db.writeLogRecord(r.record)
+		if err != nil {
+			r.err = err
+			continue
+		}
+		positions[i] = pos
+		written = true
+	}
+	if !written {
+		return
+	}
+
+	db.syncing = true
+	activeFile, activeBlobFile := db.activeFile, db.activeBlobFile
+	db.mu.Unlock()
+	var start = time.Now()
+	err := syncFiles(activeBlobFile, activeFile)
+	db.observeOperation(MetricSyncTotal, MetricSyncDuration, start)
+	db.mu.Lock()
+	db.syncing = false
+	db.syncDone.Broadcast()
+
+	if err != nil {
+		for i, r := range group {
+			if positions[i] != nil {
+				r.err = err
+			}
+		}
+		return
+	}
+	db.bytesWrite = 0
+
+	for i, r := range group {
+		if positions[i] != nil {
+			r.err = r.apply(positions[i])
+		}
+	}
+}
+
+// syncFiles 依次持久化不为 nil 的文件
+func syncFiles(files ...*data.File) error {
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockForWrite 获取 db.mu 的写锁，并等待正在释放锁执行 sync 的组提交更新完索引
+// 修改数据文件或者内存索引的操作都需要通过它加锁，只读取数据的操作直接使用 db.mu
+func (db *DB) lockForWrite() {
+	db.mu.Lock()
+	for db.syncing {
+		db.syncDone.Wait()
+	}
+}
@@ -0,0 +1,222 @@
+package bitcask_go
+
+import (
+	"fmt"
+	"github.com/xiecang/bitcask/fio"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingSyncIOManager 第一次 sync 时通知 syncing，并阻塞到 release 关闭
+type blockingSyncIOManager struct {
+	fio.IOManager
+	syncing chan struct{}
+	release chan struct{}
+}
+
+func (m *blockingSyncIOManager) Sync() error {
+	select {
+	case m.syncing <- struct{}{}:
+	default:
+	}
+	<-m.release
+	return m.IOManager.Sync()
+}
+
+// countingSink 记录每个计数器的累计值
+type countingSink struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+func (s *countingSink) IncrCounter(name string, delta uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[name] += delta
+}
+
+func (s *countingSink) ObserveHistogram(string, float64) {}
+
+func (s *countingSink) SetGauge(string, float64) {}
+
+func (s *countingSink) counter(name string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[name]
+}
+
+func TestDB_groupCommit(t *testing.T) {
+	tests := []struct {
+		name               string
+		disableGroupCommit bool
+	}{
+		{name: "group commit", disableGroupCommit: false},
+		{name: "disable group commit", disableGroupCommit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.SyncWrites = true
+			options.MaxFileSize = 4 * 1024
+			options.DisableGroupCommit = tt.disableGroupCommit
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer func() { destroyDB(db) }()
+
+			var wg sync.WaitGroup
+			for g := 0; g < 8; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < 50; i++ {
+						key := []byte(fmt.Sprintf("key-%d-%d", g, i))
+						if err := db.Put(key, key); err != nil {
+							t.Errorf("Put() error = %v", err)
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+
+			// 重启之后所有写入都可以读取到
+			if err = db.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			if db, err = Open(options); err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			if got := db.Stat().KeyNum; got != 400 {
+				t.Errorf("KeyNum = %v, want 400", got)
+			}
+			for g := 0; g < 8; g++ {
+				for i := 0; i < 50; i++ {
+					key := []byte(fmt.Sprintf("key-%d-%d", g, i))
+					if value, err := db.Get(key); err != nil || string(value) != string(key) {
+						t.Errorf("Get(%s) = %s, error = %v", key, value, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDB_groupCommit_sharedSync(t *testing.T) {
+	sink := &countingSink{counters: make(map[string]uint64)}
+	options := defaultOptions()
+	options.SyncWrites = true
+	options.Metrics = sink
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { destroyDB(db) }()
+
+	// 持有写锁，让并发的写入全部进入队列之后再提交
+	const writers = 32
+	db.mu.Lock()
+	var wg sync.WaitGroup
+	for g := 0; g < writers; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			if err := db.Put([]byte(fmt.Sprintf("key-%d", g)), []byte("value")); err != nil {
+				t.Errorf("Put() error = %v", err)
+			}
+		}(g)
+	}
+	for {
+		db.writeMu.Lock()
+		queued := len(db.writeQueue)
+		db.writeMu.Unlock()
+		if queued == writers {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	var before = sink.counter(MetricSyncTotal)
+	db.mu.Unlock()
+	wg.Wait()
+
+	// 第一个 leader 只带走自己，其余的写入由下一个 leader 一起提交
+	if got := sink.counter(MetricSyncTotal) - before; got > 2 {
+		t.Errorf("%d concurrent writers triggered %d syncs, want at most 2", writers, got)
+	}
+	if got := sink.counter(MetricPutTotal); got != writers {
+		t.Errorf("put counter = %d, want %d", got, writers)
+	}
+}
+
+func TestDB_groupCommit_readDuringSync(t *testing.T) {
+	options := defaultOptions()
+	options.SyncWrites = true
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { destroyDB(db) }()
+	_ = db.Put([]byte("key"), []byte("old"))
+
+	manager := &blockingSyncIOManager{syncing: make(chan struct{}, 1), release: make(chan struct{})}
+	db.mu.Lock()
+	manager.IOManager = db.activeFile.IOManager
+	db.activeFile.IOManager = manager
+	db.mu.Unlock()
+
+	var putDone = make(chan error, 1)
+	go func() { putDone <- db.Put([]byte("key"), []byte("new")) }()
+	<-manager.syncing
+
+	// sync 期间读取不会阻塞，尚未持久化的写入不可见
+	var getDone = make(chan []byte, 1)
+	go func() {
+		value, _ := db.Get([]byte("key"))
+		getDone <- value
+	}()
+	select {
+	case value := <-getDone:
+		if string(value) != "old" {
+			t.Errorf("Get() during sync = %s, want old", value)
+		}
+	case <-time.After(time.Second):
+		close(manager.release)
+		<-putDone
+		t.Fatalf("Get() blocked by the group commit sync")
+	}
+
+	// 其他写入方等待本组更新索引之后再执行，新的位置不会被覆盖
+	var batchDone = make(chan error, 1)
+	go func() {
+		wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+		_ = wb.Put([]byte("key"), []byte("batch"))
+		batchDone <- wb.Commit()
+	}()
+	select {
+	case <-batchDone:
+		t.Errorf("batch commit finished during the group commit sync")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(manager.release)
+	if err = <-putDone; err != nil {
+		t.Errorf("Put() error = %v", err)
+	}
+	if err = <-batchDone; err != nil {
+		t.Errorf("Commit() error = %v", err)
+	}
+
+	var check = func(stage string) {
+		if value, _ := db.Get([]byte("key")); string(value) != "batch" {
+			t.Errorf("%s Get() = %s, want batch", stage, value)
+		}
+	}
+	check("after commit")
+	_ = db.Close()
+	if db, err = Open(options); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	check("reopen")
+}
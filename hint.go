@@ -0,0 +1,127 @@
+package bitcask_go
+
+import (
+	"github.com/xiecang/bitcask/data"
+	"github.com/xiecang/bitcask/fio"
+	"os"
+)
+
+// 每个数据文件写满或者数据库关闭时，将数据文件中所有记录的索引信息写入同名的 hint 文件
+// 启动时优先从 hint 文件中加载索引，只需要扫描 hint 文件没有覆盖到的部分
+// hint 文件中的记录和数据文件中的记录一一对应并保持顺序，事务数据和删除记录同样会被保留
+
+// dataHintEnabled 是否需要为数据文件生成 hint 文件
+func (db *DB) dataHintEnabled() bool {
+	return !db.options.ReadOnly && db.options.IndexType != BPlusTree
+}
+
+// appendDataHint 将活跃数据文件中一条记录的索引信息追加到内存中，需要持有 db.mu
+// record 为未加密的记录，value 存储在 blob 文件中时同时保存 blob 文件中的位置
+func (db *DB) appendDataHint(record *data.LogRecord, pos *data.LogRecordPos) error {
+	entry := &data.LogRecord{
+		Key:         record.Key,
+		Value:       data.EncodeLogRecordPos(pos),
+		Type:        record.Type,
+		Namespace:   record.Namespace,
+		ValueInBlob: record.ValueInBlob,
+	}
+	if record.ValueInBlob {
+		entry.Value = data.EncodeBlobHint(pos, data.DecodeLogRecordPos(record.Value))
+	} else if record.Type == data.LogRecordTypeRangeDelete {
+		entry.Value = data.EncodeRangeHint(pos, record.Value)
+	}
+	entry, err := db.encryptRecord(entry)
+	if err != nil {
+		return err
+	}
+	encoded, _ := data.EncodeLogRecord(entry)
+	db.activeHint = append(db.activeHint, encoded...)
+	return nil
+}
+
+// writeDataHint 将活跃数据文件的索引信息写入 hint 文件，需要持有 db.mu
+// 先写入临时文件再重命名，不会留下不完整的 hint 文件
+func (db *DB) writeDataHint(fileId uint32) error {
+	if len(db.activeHint) == 0 {
+		return nil
+	}
+	hintPath := data.GetDataHintFilePath(db.options.DirPath, fileId)
+	if err := os.WriteFile(hintPath+".tmp", db.activeHint, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(hintPath+".tmp", hintPath); err != nil {
+		return err
+	}
+	db.activeHint = db.activeHint[:0]
+	return nil
+}
+
+// loadDataHint 从数据文件对应的 hint 文件中加载索引，返回 hint 文件覆盖到的数据文件的偏移量
+// hint 文件不存在或者损坏时，只使用其中有效的部分，剩余的数据由调用方扫描数据文件加载
+func (db *DB) loadDataHint(file *data.File, fn func(record *data.LogRecord, pos *data.LogRecordPos) error) (int64, error) {
+	if _, err := os.Stat(data.GetDataHintFilePath(db.options.DirPath, file.Id)); err != nil {
+		return 0, nil
+	}
+	ioType := fio.FIOStandar
+	if db.options.MMapAtStartup {
+		ioType = fio.FIOMemoryMap
+	}
+	hintFile, err := data.OpenDataHintFile(db.options.DirPath, file.Id, ioType)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = hintFile.Close() }()
+	fileSize, err := file.IOManager.Size()
+	if err != nil {
+		return 0, err
+	}
+
+	var offset, hintOffset int64
+	for {
+		entry, size, err := hintFile.ReadLogRecord(hintOffset)
+		if err != nil {
+			break
+		}
+		if err = db.decryptRecord(entry); err != nil {
+			break
+		}
+		record := &data.LogRecord{
+			Key:         entry.Key,
+			Type:        entry.Type,
+			Namespace:   entry.Namespace,
+			ValueInBlob: entry.ValueInBlob,
+		}
+		var pos *data.LogRecordPos
+		if entry.ValueInBlob {
+			var blobPos *data.LogRecordPos
+			pos, blobPos = data.DecodeBlobHint(entry.Value)
+			record.Value = data.EncodeLogRecordPos(blobPos)
+		} else if entry.Type == data.LogRecordTypeRangeDelete {
+			pos, record.Value = data.DecodeRangeHint(entry.Value)
+		} else {
+			pos = data.DecodeLogRecordPos(entry.Value)
+		}
+		// hint 文件中的记录必须和数据文件中的记录首尾相连，否则说明 hint 文件和数据文件不一致
+		if pos.Fid != file.Id || pos.Offset != offset || pos.Offset+int64(pos.Size) > fileSize {
+			break
+		}
+		record.Expire = pos.Expire
+		if err = fn(record, pos); err != nil {
+			return 0, err
+		}
+		offset += int64(pos.Size)
+		hintOffset += size
+	}
+	return offset, nil
+}
+
+// removeDataHints 删除 id 小于 fileId 的数据文件对应的 hint 文件
+func removeDataHints(dirPath string, fileId uint32) error {
+	for id := uint32(0); id < fileId; id++ {
+		hintPath := data.GetDataHintFilePath(dirPath, id)
+		if err := os.Remove(hintPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package bitcask_go
+
+import (
+	"fmt"
+	"github.com/xiecang/bitcask/data"
+	"os"
+	"testing"
+)
+
+func TestDB_dataHint(t *testing.T) {
+	tests := []struct {
+		name       string
+		encryption bool
+		corrupt    func(t *testing.T, dirPath string)
+		wantHints  bool
+	}{
+		{
+			name:      "use hint",
+			wantHints: true,
+		},
+		{
+			name:       "encrypted hint",
+			encryption: true,
+			wantHints:  true,
+		},
+		{
+			// hint 文件末尾不完整时，只使用有效的部分，剩余的数据扫描数据文件加载
+			name: "torn hint",
+			corrupt: func(t *testing.T, dirPath string) {
+				hintPath := data.GetDataHintFilePath(dirPath, 0)
+				info, err := os.Stat(hintPath)
+				if err != nil {
+					t.Fatalf("Stat() error = %v", err)
+				}
+				if err = os.Truncate(hintPath, info.Size()/2); err != nil {
+					t.Fatalf("Truncate() error = %v", err)
+				}
+			},
+		},
+		{
+			name: "missing hint",
+			corrupt: func(t *testing.T, dirPath string) {
+				if err := os.Remove(data.GetDataHintFilePath(dirPath, 1)); err != nil {
+					t.Fatalf("Remove() error = %v", err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.MaxFileSize = 512
+			if tt.encryption {
+				options.EncryptionKey = []byte("0123456789abcdef")
+			}
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer func() { destroyDB(db) }()
+
+			for i := 0; i < 50; i++ {
+				_ = db.Put([]byte(fmt.Sprintf("key-%d", i)), []byte(fmt.Sprintf("value-%d", i)))
+			}
+			for i := 0; i < 10; i++ {
+				_ = db.Delete([]byte(fmt.Sprintf("key-%d", i)))
+			}
+			// 批量写入的数据跨越多个数据文件，完成标记之前的数据不能单独生效
+			wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+			for i := 10; i < 30; i++ {
+				_ = wb.Put([]byte(fmt.Sprintf("key-%d", i)), []byte(fmt.Sprintf("batch-%d", i)))
+			}
+			if err = wb.Commit(); err != nil {
+				t.Errorf("Commit() error = %v", err)
+			}
+			if err = db.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+
+			// 每个数据文件都有对应的 hint 文件
+			fileIds, _ := dataFileIds(options.DirPath)
+			if len(fileIds) < 3 {
+				t.Errorf("data files = %v, want at least 3", len(fileIds))
+			}
+			for _, fid := range fileIds {
+				if _, err = os.Stat(data.GetDataHintFilePath(options.DirPath, uint32(fid))); err != nil {
+					t.Errorf("hint file of %d error = %v", fid, err)
+				}
+			}
+			if tt.corrupt != nil {
+				tt.corrupt(t, options.DirPath)
+			}
+			if tt.wantHints {
+				// 被 hint 文件覆盖的数据不再扫描，损坏第一个数据文件中第一条记录的校验值不影响启动
+				f, _ := os.OpenFile(data.GetFilePath(options.DirPath, 0), os.O_RDWR, 0644)
+				_, _ = f.WriteAt([]byte{0xff}, 0)
+				_ = f.Close()
+			}
+
+			if db, err = Open(options); err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			if got := len(db.ListKeys()); got != 40 {
+				t.Errorf("ListKeys() len = %v, want 40", got)
+			}
+			for i := 10; i < 50; i++ {
+				var want = fmt.Sprintf("value-%d", i)
+				if i < 30 {
+					want = fmt.Sprintf("batch-%d", i)
+				}
+				if got, err := db.Get([]byte(fmt.Sprintf("key-%d", i))); err != nil || string(got) != want {
+					t.Errorf("Get(key-%d) = %s, error = %v, want %s", i, got, err, want)
+				}
+			}
+
+			// 活跃文件继续写入之后，重启仍然可以读取到全部数据
+			_ = db.Put([]byte("key-0"), []byte("value-0"))
+			if err = db.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			if db, err = Open(options); err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			if got := len(db.ListKeys()); got != 41 {
+				t.Errorf("ListKeys() after reopen len = %v, want 41", got)
+			}
+		})
+	}
+}
+
+func TestDB_dataHint_merge(t *testing.T) {
+	options := defaultOptions()
+	options.MaxFileSize = 512
+	db, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer func() { destroyDB(db) }()
+
+	for i := 0; i < 50; i++ {
+		_ = db.Put([]byte(fmt.Sprintf("key-%d", i%10)), []byte(fmt.Sprintf("value-%d", i)))
+	}
+	if err = db.Merge(); err != nil {
+		t.Errorf("Merge() error = %v", err)
+	}
+	if err = db.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	// merge 替换的数据文件对应的旧 hint 文件会被删除，第二次启动时使用 merge 之后生成的 hint 文件
+	for round := 0; round < 2; round++ {
+		if round > 0 {
+			if err = db.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		}
+		if db, err = Open(options); err != nil {
+			t.Errorf("Open() error = %v", err)
+			return
+		}
+		for i := 0; i < 10; i++ {
+			if got, _ := db.Get([]byte(fmt.Sprintf("key-%d", i))); string(got) != fmt.Sprintf("value-%d", 40+i) {
+				t.Errorf("round %d Get(key-%d) = %s", round, i, got)
+			}
+		}
+	}
+}
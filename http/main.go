@@ -8,10 +8,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 )
 
 var (
-	db *bitcask.DB
+	db      *bitcask.DB
+	metrics = bitcask.NewPrometheusSink()
 )
 
 func init() {
@@ -20,6 +22,7 @@ func init() {
 	var options = bitcask.DefaultOptions
 	dir, _ := os.MkdirTemp("", "bitcask-go-http")
 	options.DirPath = dir
+	options.Metrics = metrics
 	db, err = bitcask.Open(options)
 	if err != nil {
 		panic(fmt.Sprintf("failed to open db: %v", err))
@@ -125,6 +128,66 @@ func handleStat(writer http.ResponseWriter, request *http.Request) {
 	})
 }
 
+func handleSetNX(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var data = make(map[string]string)
+	if err := json.NewDecoder(request.Body).Decode(&data); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result = make(map[string]bool)
+	for k, v := range data {
+		ok, err := db.PutIfAbsent([]byte(k), []byte(v))
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			log.Printf("failed to setnx key: %s, value: %s, err: %v", k, v, err)
+			return
+		}
+		result[k] = ok
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(result)
+}
+
+func handleIncr(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := request.URL.Query().Get("key")
+	var delta int64 = 1
+	if d := request.URL.Query().Get("delta"); d != "" {
+		var err error
+		if delta, err = strconv.ParseInt(d, 10, 64); err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	value, err := db.IncrBy([]byte(key), delta)
+	if err != nil {
+		var status = http.StatusInternalServerError
+		if errors.Is(err, bitcask.ErrValueNotInteger) || errors.Is(err, bitcask.ErrIncrOverflow) ||
+			errors.Is(err, bitcask.ErrKeyIsEmpty) {
+			status = http.StatusBadRequest
+		}
+		http.Error(writer, err.Error(), status)
+		log.Printf("failed to incr key: %s, err: %v", key, err)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(map[string]int64{
+		"value": value,
+	})
+}
+
 func main() {
 
 	http.HandleFunc("/bitcask/put", handlePut)
@@ -132,6 +195,9 @@ func main() {
 	http.HandleFunc("/bitcask/delete", handleDelete)
 	http.HandleFunc("/bitcask/listkeys", handleListKeys)
 	http.HandleFunc("/bitcask/stat", handleStat)
+	http.HandleFunc("/bitcask/setnx", handleSetNX)
+	http.HandleFunc("/bitcask/incr", handleIncr)
+	http.Handle("/metrics", metrics)
 
 	_ = http.ListenAndServe(":8080", nil)
 }
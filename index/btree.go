@@ -68,6 +68,16 @@ func (bt *BTree) Close() error {
 	return nil
 }
 
+// Clone 返回索引的写时复制副本，副本和原索引之后的修改互不影响
+func (bt *BTree) Clone() *BTree {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+	return &BTree{
+		tree: bt.tree.Clone(),
+		lock: &sync.RWMutex{},
+	}
+}
+
 func (bt *BTree) Iterator(reverse bool) Iterator {
 	if bt == nil {
 		return nil
@@ -1165,3 +1165,27 @@ func Test_newBTreeIterator(t *testing.T) {
 		})
 	}
 }
+
+func TestBTree_Clone(t *testing.T) {
+	bt := NewBTree()
+	bt.Put([]byte("key"), &data.LogRecordPos{Fid: 1, Offset: 1})
+	bt.Put([]byte("key2"), &data.LogRecordPos{Fid: 1, Offset: 2})
+
+	clone := bt.Clone()
+	bt.Put([]byte("key"), &data.LogRecordPos{Fid: 2, Offset: 1})
+	bt.Put([]byte("key3"), &data.LogRecordPos{Fid: 2, Offset: 2})
+	bt.Delete([]byte("key2"))
+
+	if got := clone.Size(); got != 2 {
+		t.Errorf("Clone() size = %v, want 2", got)
+	}
+	if got := clone.Get([]byte("key")); !reflect.DeepEqual(got, &data.LogRecordPos{Fid: 1, Offset: 1}) {
+		t.Errorf("Clone() Get(key) = %v", got)
+	}
+	if got := clone.Get([]byte("key2")); got == nil {
+		t.Errorf("Clone() Get(key2) = nil, want not nil")
+	}
+	if got := clone.Get([]byte("key3")); got != nil {
+		t.Errorf("Clone() Get(key3) = %v, want nil", got)
+	}
+}
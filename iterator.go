@@ -2,7 +2,10 @@ package bitcask_go
 
 import (
 	"bytes"
+	"context"
 	"github.com/xiecang/bitcask/index"
+	"sync/atomic"
+	"time"
 )
 
 // Iterator 迭代器
@@ -10,10 +13,25 @@ type Iterator struct {
 	indexIter index.Iterator  // 索引迭代器
 	db        *DB             // 数据库
 	option    *IteratorOption // 迭代器选项
+	readTs    int64           // 判断数据是否过期的时刻，为 0 时使用当前时间
+	ctx       context.Context // 取消之后停止遍历，为 nil 时不会取消
+	closed    bool
 }
 
 func (db *DB) NewIterator(opt *IteratorOption) *Iterator {
-	indexIter := db.index.Iterator(opt.Reverse)
+	return db.NewIteratorContext(context.Background(), opt)
+}
+
+// NewIteratorContext 创建绑定 ctx 的迭代器，ctx 取消之后 Valid 返回 false，Err 和 Value 返回 ctx.Err()
+func (db *DB) NewIteratorContext(ctx context.Context, opt *IteratorOption) *Iterator {
+	it := db.newIterator(db.index.Iterator(opt.Reverse), opt)
+	it.ctx = ctx
+	return it
+}
+
+// newIterator 创建遍历 indexIter 的迭代器，并记录尚未关闭的迭代器数量
+func (db *DB) newIterator(indexIter index.Iterator, opt *IteratorOption) *Iterator {
+	atomic.AddInt32(&db.iterators, 1)
 	return &Iterator{
 		indexIter: indexIter,
 		db:        db,
@@ -37,7 +55,15 @@ func (i *Iterator) Next() {
 }
 
 func (i *Iterator) Valid() bool {
-	return i.indexIter.Valid()
+	return i.Err() == nil && i.indexIter.Valid()
+}
+
+// Err 返回迭代器因为 ctx 取消而停止遍历的原因
+func (i *Iterator) Err() error {
+	if i.ctx == nil {
+		return nil
+	}
+	return i.ctx.Err()
 }
 
 func (i *Iterator) Key() []byte {
@@ -45,6 +71,9 @@ func (i *Iterator) Key() []byte {
 }
 
 func (i *Iterator) Value() ([]byte, error) {
+	if err := i.Err(); err != nil {
+		return nil, err
+	}
 	pos := i.indexIter.Value()
 	if pos == nil {
 		return nil, ErrKeyNotFound
@@ -55,15 +84,28 @@ func (i *Iterator) Value() ([]byte, error) {
 }
 
 func (i *Iterator) Close() {
+	if i.closed {
+		return
+	}
+	i.closed = true
 	i.indexIter.Close()
+	atomic.AddInt32(&i.db.iterators, -1)
 }
 
+// skipToNext 跳过不满足前缀条件以及已经过期的数据
 func (i *Iterator) skipToNext() {
 	var prefixLen = len(i.option.Prefix)
-	if prefixLen == 0 {
-		return
+	var now = i.readTs
+	if now == 0 {
+		now = time.Now().UnixNano()
 	}
 	for ; i.indexIter.Valid(); i.indexIter.Next() {
+		if i.indexIter.Value().IsExpired(now) {
+			continue
+		}
+		if prefixLen == 0 {
+			break
+		}
 		key := i.indexIter.Key()
 		if prefixLen <= len(key) && bytes.Compare(key[:prefixLen], i.option.Prefix) == 0 {
 			break
@@ -1,6 +1,9 @@
 package bitcask_go
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/xiecang/bitcask/data"
 	"os"
 	"path/filepath"
@@ -577,3 +580,32 @@ func TestIterator_skipToNext(t *testing.T) {
 		})
 	}
 }
+
+func TestDB_NewIteratorContext(t *testing.T) {
+	options := defaultOptions()
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+	for i := 0; i < 10; i++ {
+		_ = db.Put([]byte(fmt.Sprintf("key-%d", i)), []byte("value"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	iterator := db.NewIteratorContext(ctx, defaultIteratorOption())
+	defer iterator.Close()
+	var visited int
+	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		if visited++; visited == 3 {
+			cancel()
+		}
+	}
+	if visited != 3 || !errors.Is(iterator.Err(), context.Canceled) {
+		t.Errorf("iterator visited %d keys, Err() = %v", visited, iterator.Err())
+	}
+	if _, err = iterator.Value(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Value() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,122 @@
+package bitcask_go
+
+import (
+	"errors"
+	"github.com/xiecang/bitcask/data"
+	"io"
+)
+
+// loadedRecord 从数据文件中读取到的一条记录及其位置
+type loadedRecord struct {
+	record *data.LogRecord
+	pos    *data.LogRecordPos
+}
+
+// loadedFile 一个数据文件的读取结果
+type loadedFile struct {
+	file    *data.File
+	records []loadedRecord
+	offset  int64            // 读取结束时的偏移量
+	events  []*RecoveryEvent // 读取过程中遇到损坏的数据时执行的恢复操作，由调用方执行
+	err     error
+}
+
+// readDataFile 读取数据文件中 file.WriteOffset 之后的所有记录并解密，从头读取时优先从 hint 文件中读取
+// 不修改内存索引，可以并发读取多个数据文件
+func (db *DB) readDataFile(file *data.File) *loadedFile {
+	var result = &loadedFile{file: file}
+	var collect = func(record *data.LogRecord, pos *data.LogRecordPos) error {
+		result.records = append(result.records, loadedRecord{record: record, pos: pos})
+		return nil
+	}
+
+	// 活跃文件在只读模式下 Refresh 时从上次读取到的位置继续读取
+	var offset = file.WriteOffset
+	if offset == 0 {
+		// 优先从 hint 文件中加载，只扫描 hint 文件没有覆盖到的部分
+		if offset, result.err = db.loadDataHint(file, collect); result.err != nil {
+			return result
+		}
+	}
+	for {
+		record, size, err := file.ReadLogRecord(offset)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if db.options.ReadOnly && file.Id == db.activeFile.Id {
+				// 写入方可能正在追加数据，末尾不完整的记录留到下次 Refresh 时读取
+				break
+			}
+			// 按照 RecoveryMode 跳过或者截断损坏的数据
+			next, event, err := db.recoverCorruption(file, offset, err)
+			if err != nil {
+				result.err = err
+				return result
+			}
+			result.events = append(result.events, event)
+			if event.Action == RecoveryTruncated {
+				break
+			}
+			offset = next
+			continue
+		}
+		if err = db.decryptRecord(record); err != nil {
+			result.err = err
+			return result
+		}
+
+		// 构造内存索引信息
+		_ = collect(record, &data.LogRecordPos{
+			Fid:    file.Id,
+			Offset: offset,
+			Size:   uint32(size),
+			Expire: record.Expire,
+		})
+		offset += size
+	}
+	result.offset = offset
+	return result
+}
+
+// readDataFiles 使用最多 LoadConcurrency 个协程并发读取数据文件，并按照文件 id 的顺序依次交给 fn 处理
+// 读取完成但尚未处理的文件最多有 LoadConcurrency 个，避免占用过多的内存
+func (db *DB) readDataFiles(files []*data.File, fn func(loaded *loadedFile) error) error {
+	var concurrency = db.options.LoadConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var results = make([]chan *loadedFile, len(files))
+	for i := range results {
+		results[i] = make(chan *loadedFile, 1)
+	}
+	var slots = make(chan struct{}, concurrency)
+	var done = make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for i, file := range files {
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			go func(i int, file *data.File) {
+				results[i] <- db.readDataFile(file)
+			}(i, file)
+		}
+	}()
+
+	for i := range files {
+		loaded := <-results[i]
+		<-slots
+		if loaded.err != nil {
+			return loaded.err
+		}
+		if err := fn(loaded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package bitcask_go
+
+import (
+	"fmt"
+	"github.com/xiecang/bitcask/data"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDB_LoadConcurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		removeHints bool
+	}{
+		{name: "sequential", concurrency: 0},
+		{name: "concurrent", concurrency: 4},
+		{name: "concurrent without hint", concurrency: 4, removeHints: true},
+		{name: "more workers than files", concurrency: 64, removeHints: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.MaxFileSize = 256
+			options.LoadConcurrency = tt.concurrency
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer func() { destroyDB(db) }()
+
+			// 同一个 key 在多个文件中被多次覆盖，最后写入的数据生效
+			for round := 0; round < 3; round++ {
+				for i := 0; i < 20; i++ {
+					_ = db.Put([]byte(fmt.Sprintf("key-%d", i)), []byte(fmt.Sprintf("value-%d-%d", i, round)))
+				}
+			}
+			for i := 0; i < 5; i++ {
+				_ = db.Delete([]byte(fmt.Sprintf("key-%d", i)))
+			}
+			// 跨越多个数据文件的批量写入
+			wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+			for i := 5; i < 15; i++ {
+				_ = wb.Put([]byte(fmt.Sprintf("key-%d", i)), []byte(fmt.Sprintf("batch-%d", i)))
+			}
+			if err = wb.Commit(); err != nil {
+				t.Errorf("Commit() error = %v", err)
+			}
+			// 没有完成标记的事务数据不生效
+			db.mu.Lock()
+			for i := 15; i < 20; i++ {
+				_, _ = db.appendLogRecord(&data.LogRecord{
+					Key:   logRecordKeyWithSeq([]byte(fmt.Sprintf("key-%d", i)), db.seqId+1),
+					Value: []byte("uncommitted"),
+				})
+			}
+			db.mu.Unlock()
+			if err = db.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			if tt.removeHints {
+				hints, _ := filepath.Glob(filepath.Join(options.DirPath, "*"+data.HintFileNameSuffix))
+				for _, hint := range hints {
+					_ = os.Remove(hint)
+				}
+			}
+
+			if db, err = Open(options); err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			if stat := db.Stat(); stat.DataFileNum < 4 || stat.KeyNum != 15 {
+				t.Errorf("Stat() = %+v", stat)
+			}
+			for i := 5; i < 20; i++ {
+				var want = fmt.Sprintf("value-%d-2", i)
+				if i < 15 {
+					want = fmt.Sprintf("batch-%d", i)
+				}
+				if got, _ := db.Get([]byte(fmt.Sprintf("key-%d", i))); string(got) != want {
+					t.Errorf("Get(key-%d) = %s, want %s", i, got, want)
+				}
+			}
+			// 重建索引之后可以继续写入
+			if err = db.Put([]byte("key-0"), []byte("value")); err != nil {
+				t.Errorf("Put() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDB_LoadConcurrency_options(t *testing.T) {
+	options := defaultOptions()
+	options.LoadConcurrency = -1
+	if _, err := Open(options); err == nil {
+		t.Errorf("Open() with negative load concurrency should fail")
+	}
+}
@@ -0,0 +1,53 @@
+package bitcask_go
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger 结构化日志接口，方法签名与 *slog.Logger 一致，可以直接使用 slog.New(handler) 作为 Options.Logger
+// args 为交替出现的 key 和 value，例如 "file_id", 3, "duration", time.Second
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// stdLogger 没有设置 Options.Logger 时使用的日志，只将 Warn 和 Error 级别的事件输出到标准库 log
+type stdLogger struct{}
+
+func (stdLogger) Debug(string, ...any) {}
+
+func (stdLogger) Info(string, ...any) {}
+
+func (stdLogger) Warn(msg string, args ...any) {
+	log.Print(formatLogEvent("WARN", msg, args))
+}
+
+func (stdLogger) Error(msg string, args ...any) {
+	log.Print(formatLogEvent("ERROR", msg, args))
+}
+
+// formatLogEvent 将事件格式化为 bitcask: LEVEL msg key=value ... 的形式
+func formatLogEvent(level, msg string, args []any) string {
+	var sb strings.Builder
+	sb.WriteString("bitcask: " + level + " " + msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			_, _ = fmt.Fprintf(&sb, " !BADKEY=%v", args[i])
+			break
+		}
+		_, _ = fmt.Fprintf(&sb, " %v=%v", args[i], args[i+1])
+	}
+	return sb.String()
+}
+
+// logger 返回 Options.Logger，没有设置时返回 stdLogger
+func (db *DB) logger() Logger {
+	if db.options.Logger != nil {
+		return db.options.Logger
+	}
+	return stdLogger{}
+}
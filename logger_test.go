@@ -0,0 +1,128 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+// logEvent 测试中记录的一条日志
+type logEvent struct {
+	level string
+	msg   string
+	attrs map[string]any
+}
+
+type recordLogger struct {
+	mu     sync.Mutex
+	events []logEvent
+}
+
+func (l *recordLogger) record(level, msg string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var attrs = make(map[string]any)
+	for i := 0; i+1 < len(args); i += 2 {
+		attrs[args[i].(string)] = args[i+1]
+	}
+	l.events = append(l.events, logEvent{level: level, msg: msg, attrs: attrs})
+}
+
+func (l *recordLogger) Debug(msg string, args ...any) { l.record("DEBUG", msg, args) }
+func (l *recordLogger) Info(msg string, args ...any)  { l.record("INFO", msg, args) }
+func (l *recordLogger) Warn(msg string, args ...any)  { l.record("WARN", msg, args) }
+func (l *recordLogger) Error(msg string, args ...any) { l.record("ERROR", msg, args) }
+
+func (l *recordLogger) find(msg string) *logEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.events {
+		if l.events[i].msg == msg {
+			return &l.events[i]
+		}
+	}
+	return nil
+}
+
+func TestDB_Logger(t *testing.T) {
+	logger := &recordLogger{}
+	options := defaultOptions()
+	options.MaxFileSize = 512
+	options.DataFileMergeThreshold = 0
+	options.Logger = logger
+	var backupDir = options.DirPath + "-backup"
+	defer func() { _ = os.RemoveAll(backupDir) }()
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		_ = db.Put([]byte(fmt.Sprintf("key-%02d", i)), bytes.Repeat([]byte("v"), 32))
+	}
+	if err = db.Merge(); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if err = db.Backup(backupDir); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	_ = db.Close()
+	// 重新打开时加载 merge 目录
+	if db, err = Open(options); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	tests := []struct {
+		msg   string
+		level string
+		attrs []string
+	}{
+		{msg: "database opened", level: "INFO", attrs: []string{"dir", "data_files", "keys", "duration"}},
+		{msg: "data file rotated", level: "INFO", attrs: []string{"file_id", "bytes", "new_file_id"}},
+		{msg: "merge started", level: "DEBUG", attrs: []string{"files", "non_merge_file_id"}},
+		{msg: "merge finished", level: "INFO", attrs: []string{"files", "reclaimed_bytes", "duration"}},
+		{msg: "backup finished", level: "INFO", attrs: []string{"dir", "bytes", "duration"}},
+		{msg: "database closed", level: "INFO", attrs: []string{"dir", "active_file_id"}},
+		{msg: "merge files loaded", level: "INFO", attrs: []string{"files", "non_merge_file_id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			event := logger.find(tt.msg)
+			if event == nil {
+				t.Fatalf("event %q not logged", tt.msg)
+			}
+			if event.level != tt.level {
+				t.Errorf("event %q level = %s, want %s", tt.msg, event.level, tt.level)
+			}
+			for _, attr := range tt.attrs {
+				if _, ok := event.attrs[attr]; !ok {
+					t.Errorf("event %q missing attribute %q: %v", tt.msg, attr, event.attrs)
+				}
+			}
+		})
+	}
+	if event := logger.find("database opened"); event.attrs["keys"] != 0 {
+		t.Errorf("first open keys = %v, want 0", event.attrs["keys"])
+	}
+}
+
+func Test_formatLogEvent(t *testing.T) {
+	tests := []struct {
+		name string
+		args []any
+		want string
+	}{
+		{name: "no args", want: "bitcask: WARN msg"},
+		{name: "pairs", args: []any{"file_id", 3, "error", "eof"}, want: "bitcask: WARN msg file_id=3 error=eof"},
+		{name: "odd args", args: []any{"file_id", 3, "dangling"}, want: "bitcask: WARN msg file_id=3 !BADKEY=dangling"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLogEvent("WARN", "msg", tt.args); got != tt.want {
+				t.Errorf("formatLogEvent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
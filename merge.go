@@ -1,6 +1,7 @@
 package bitcask_go
 
 import (
+	"context"
 	"github.com/xiecang/bitcask/data"
 	"github.com/xiecang/bitcask/utils"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"path"
 	"sort"
 	"strconv"
+	"time"
 )
 
 const (
@@ -20,7 +22,7 @@ func (db *DB) getMergeFiles() (mergeFiles []*data.File, nonMergeFileId uint32, e
 		// 数据库为空，直接返回
 		return
 	}
-	db.mu.Lock()
+	db.lockForWrite()
 	defer db.mu.Unlock()
 
 	if db.isMerging {
@@ -36,6 +38,13 @@ func (db *DB) getMergeFiles() (mergeFiles []*data.File, nonMergeFileId uint32, e
 		return
 	}
 
+	// blob 文件由 BlobGC 单独回收，不参与 merge
+	var blobSize int64
+	if blobSize, _, err = db.blobStat(); err != nil {
+		return
+	}
+	totalSize -= blobSize
+
 	if float32(db.reclaimableSize)/float32(totalSize) < db.options.DataFileMergeThreshold {
 		// 数据量未达到阈值，直接返回
 		err = ErrMergeThresholdNotReached
@@ -59,15 +68,8 @@ func (db *DB) getMergeFiles() (mergeFiles []*data.File, nonMergeFileId uint32, e
 		db.isMerging = false
 	}()
 
-	// 持久化当前活跃文件
-	if err = db.activeFile.Sync(); err != nil {
-		return
-	}
-
-	// 将当前活跃文件加入旧文件列表
-	db.olderFiles[db.activeFile.Id] = db.activeFile
-	// 打开新的活跃文件
-	if err = db.setActivateDataFile(); err != nil {
+	// 持久化当前活跃文件，并打开新的活跃文件
+	if err = db.rotateActiveFile(); err != nil {
 		return
 	}
 
@@ -86,13 +88,54 @@ func (db *DB) getMergePath() string {
 	return path.Join(dir, base+mergeDirName)
 }
 
+// mergePending 判断 merge 目录中是否有已经完成但尚未在打开时加载的结果
+func (db *DB) mergePending() bool {
+	_, err := os.Stat(data.MergeFinishedFileName(db.getMergePath()))
+	return err == nil
+}
+
 // Merge 清理无效数据，生成 Hint 文件
 func (db *DB) Merge() error {
-	var mergeFiles, nonMergeFileId, err = db.getMergeFiles()
+	return db.MergeContext(context.Background())
+}
+
+// MergeContext 与 Merge 相同，ctx 取消时停止合并并返回 ctx.Err()
+// 取消或者失败时删除写了一半的 merge 目录，数据目录保持合并之前的状态
+func (db *DB) MergeContext(ctx context.Context) (err error) {
+	if db.options.ReadOnly {
+		return ErrReadOnly
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	mergeFiles, nonMergeFileId, err := db.getMergeFiles()
 	if err != nil {
 		return err
 	}
 
+	// 合并前后数据文件大小的差值即为回收的数据量
+	var readBytes, writtenBytes int64
+	db.logger().Debug("merge started", "files", len(mergeFiles), "non_merge_file_id", nonMergeFileId)
+	defer func(start time.Time) {
+		db.observeOperation(MetricMergeTotal, MetricMergeDuration, start)
+		if err != nil {
+			db.logger().Error("merge failed", "files", len(mergeFiles), "duration", time.Since(start), "error", err)
+			return
+		}
+		var reclaimed int64
+		if readBytes > writtenBytes {
+			reclaimed = readBytes - writtenBytes
+			db.incrCounter(MetricMergeReclaimed, uint64(reclaimed))
+		}
+		db.logger().Info("merge finished",
+			"files", len(mergeFiles),
+			"non_merge_file_id", nonMergeFileId,
+			"read_bytes", readBytes,
+			"written_bytes", writtenBytes,
+			"reclaimed_bytes", reclaimed,
+			"duration", time.Since(start))
+	}(time.Now())
+
 	// 将待合并的文件列表按照文件 ID 从小到大排序
 	sort.Slice(mergeFiles, func(i, j int) bool {
 		return mergeFiles[i].Id < mergeFiles[j].Id
@@ -114,6 +157,11 @@ func (db *DB) Merge() error {
 	mergeOption := db.options
 	mergeOption.DirPath = mergePath
 	mergeOption.SyncWrites = false
+	mergeOption.AutoMergeInterval = 0
+	// value 已经存储在 blob 文件中的记录只拷贝位置，不在 merge 目录中生成新的 blob 文件
+	mergeOption.LargeValueThreshold = 0
+	mergeOption.BlobGCInterval = 0
+	mergeOption.Metrics = nil
 	mergeDB, err := Open(mergeOption)
 	if err != nil {
 		return err
@@ -122,13 +170,30 @@ func (db *DB) Merge() error {
 	// 打开 hint 文件存储索引
 	hintFile, err := data.OpenHintFile(mergePath)
 	if err != nil {
+		_ = mergeDB.Close()
+		_ = os.RemoveAll(mergePath)
 		return err
 	}
+	// 无论是否成功都关闭 hint 文件和临时实例，释放文件句柄、索引和 merge 目录的文件锁
+	// 失败或者取消时删除写了一半的 merge 目录
+	defer func() {
+		_ = hintFile.Close()
+		if closeErr := mergeDB.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.RemoveAll(mergePath)
+		}
+	}()
 
 	// 将旧文件中的数据写入新的临时 bitcask 实例
+	var now = time.Now().UnixNano()
 	for _, file := range mergeFiles {
 		var offset int64 = 0
 		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 			record, size, err := file.ReadLogRecord(offset)
 			if err != nil {
 				if err == io.EOF {
@@ -136,24 +201,48 @@ func (db *DB) Merge() error {
 				}
 				return err
 			}
+			// 解密之后由 mergeDB 使用当前的密钥重新加密
+			if err = db.decryptRecord(record); err != nil {
+				return err
+			}
 
 			realKey, _ := parsedLogRecordKey(record.Key)
-			pos := db.index.Get(realKey)
-			// 和内存索引比较，如果内存索引中存在这个 key，说明这个 key 是有效的
-			if pos != nil && pos.Fid == file.Id && pos.Offset == offset {
+			var pos *data.LogRecordPos
+			// 记录所属命名空间的内存索引，命名空间被删除的数据不会出现在索引中
+			if indexer := db.lookupNamespaceIndex(record.Namespace); indexer != nil {
+				pos = indexer.Get(realKey)
+			}
+			// 和内存索引比较，如果内存索引中存在这个 key，说明这个 key 是有效的, 已过期的 key 直接丢弃
+			if pos != nil && pos.Fid == file.Id && pos.Offset == offset && !pos.IsExpired(now) {
 				// 清除事务标记
 				record.Key = logRecordKeyWithSeq(realKey, nonTransactionSeqId)
+				// 压缩算法和当前配置不一致的记录，解压之后按照当前的压缩算法重新压缩
+				if db.compressor == nil || record.Codec != db.compressor.Type() {
+					if err = db.decompressRecord(record); err != nil {
+						return err
+					}
+				}
 				p, err := mergeDB.appendLogRecord(record)
 				if err != nil {
 					return err
 				}
+				writtenBytes += int64(p.Size)
 				// 将当前位置索引写入 Hint 文件
-				if err = hintFile.WriteHintRecord(realKey, p); err != nil {
+				var hintRecord = data.NewHintRecord(record.Namespace, realKey, p)
+				if record.ValueInBlob {
+					hintRecord = data.NewBlobHintRecord(record.Namespace, realKey, p, data.DecodeLogRecordPos(record.Value))
+				}
+				hintRecord, err = db.encryptRecord(hintRecord)
+				if err != nil {
+					return err
+				}
+				if err = hintFile.WriteLogRecord(hintRecord); err != nil {
 					return err
 				}
 			}
 			offset += size
 		}
+		readBytes += offset
 	}
 
 	// sync hint file
@@ -174,6 +263,8 @@ func (db *DB) Merge() error {
 		Value: []byte(strconv.Itoa(int(nonMergeFileId))),
 	}
 
+	defer func() { _ = mergeFinishedFile.Close() }()
+
 	encodedRecord, _ := data.EncodeLogRecord(&finishedRecord)
 	if err = mergeFinishedFile.Write(encodedRecord); err != nil {
 		return err
@@ -233,6 +324,7 @@ func (db *DB) loadMergeFiles() error {
 
 	// 如果没有 merge 完成的文件，说明上次合并过程中出现了异常
 	if !mergeFinished {
+		db.logger().Warn("unfinished merge directory removed", "dir", mergePath)
 		return nil
 	}
 
@@ -251,6 +343,10 @@ func (db *DB) loadMergeFiles() error {
 			}
 		}
 	}
+	// 旧数据文件对应的 hint 文件同样失效
+	if err = removeDataHints(db.options.DirPath, nonMergeFileId); err != nil {
+		return err
+	}
 	// 将新的数据文件移动到数据目录
 	for _, fileName := range mergeFileNames {
 		// 每次合并都会生成一个新的数据文件，文件名为 0000.data 这种格式，id 均是从 0 递增
@@ -262,6 +358,10 @@ func (db *DB) loadMergeFiles() error {
 			return err
 		}
 	}
+	db.logger().Info("merge files loaded",
+		"dir", mergePath,
+		"files", len(mergeFileNames),
+		"non_merge_file_id", nonMergeFileId)
 	return nil
 }
 
@@ -289,8 +389,18 @@ func (db *DB) loadIndexFromHintFile() error {
 			return err
 		}
 
-		pos := data.DecodeLogRecordPos(record.Value)
-		db.index.Put(record.Key, pos)
+		if err = db.decryptRecord(record); err != nil {
+			return err
+		}
+		var pos *data.LogRecordPos
+		if record.ValueInBlob {
+			var blobPos *data.LogRecordPos
+			pos, blobPos = data.DecodeBlobHint(record.Value)
+			db.blobRefs[blobRefOf(pos)] = blobPos
+		} else {
+			pos = data.DecodeLogRecordPos(record.Value)
+		}
+		db.indexOf(db.namespaceOf(record.Namespace)).Put(record.Key, pos)
 		offset += size
 	}
 	return nil
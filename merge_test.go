@@ -2,10 +2,15 @@ package bitcask_go
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofrs/flock"
 	"github.com/xiecang/bitcask/data"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestDB_Merge(t *testing.T) {
@@ -308,3 +313,166 @@ func TestDB_Merge(t *testing.T) {
 		})
 	}
 }
+
+func TestDB_Merge_expired(t *testing.T) {
+	options := defaultOptions()
+	db, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer func() { destroyDB(db) }()
+	if err = db.PutWithTTL([]byte("key"), []byte("value"), time.Millisecond); err != nil {
+		t.Errorf("PutWithTTL() error = %v", err)
+	}
+	if err = db.PutWithTTL([]byte("key2"), []byte("value2"), time.Hour); err != nil {
+		t.Errorf("PutWithTTL() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err = db.Merge(); err != nil {
+		t.Errorf("Merge() error = %v", err)
+	}
+	if err = db.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	// merge 后过期的数据被丢弃，未过期的数据通过 hint 文件保留过期时间
+	db, err = Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	if db.index.Get([]byte("key")) != nil {
+		t.Errorf("Merge() expired key still in index")
+	}
+	pos := db.index.Get([]byte("key2"))
+	if pos == nil || pos.Expire == 0 {
+		t.Errorf("Merge() key2 pos = %+v, want expire", pos)
+	}
+	if ttl, err := db.TTL([]byte("key2")); err != nil || ttl <= 0 {
+		t.Errorf("TTL() = %v, error = %v", ttl, err)
+	}
+}
+
+// countdownContext 在 Err 被调用 n 次之后变为已取消，用于在操作进行到一半时取消
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n--; c.n < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestDB_MergeContext(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  func() context.Context
+	}{
+		{name: "cancelled before start", ctx: func() context.Context {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx
+		}},
+		{name: "cancelled during merge", ctx: func() context.Context {
+			return &countdownContext{Context: context.Background(), n: 50}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.MaxFileSize = 512
+			options.DataFileMergeThreshold = 0
+			db, err := Open(options)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer func() { destroyDB(db) }()
+			for i := 0; i < 100; i++ {
+				_ = db.Put([]byte(fmt.Sprintf("key-%03d", i)), []byte(fmt.Sprintf("value-%03d", i)))
+			}
+			for i := 0; i < 100; i += 2 {
+				_ = db.Delete([]byte(fmt.Sprintf("key-%03d", i)))
+			}
+
+			if err = db.MergeContext(tt.ctx()); !errors.Is(err, context.Canceled) {
+				t.Fatalf("MergeContext() error = %v, want %v", err, context.Canceled)
+			}
+			if _, err = os.Stat(db.getMergePath()); !os.IsNotExist(err) {
+				t.Errorf("MergeContext() left the merge directory")
+			}
+
+			// 重新打开之后数据保持不变
+			_ = db.Close()
+			if db, err = Open(options); err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			for i := 0; i < 100; i++ {
+				value, err := db.Get([]byte(fmt.Sprintf("key-%03d", i)))
+				if i%2 == 0 {
+					if err != ErrKeyNotFound {
+						t.Errorf("Get(key-%03d) error = %v, want %v", i, err, ErrKeyNotFound)
+					}
+				} else if string(value) != fmt.Sprintf("value-%03d", i) {
+					t.Errorf("Get(key-%03d) = %s, error = %v", i, value, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDB_Merge_releasesMergeDB(t *testing.T) {
+	tests := []struct {
+		name    string
+		breakDB func(provider *testKeyProvider)
+		wantErr error
+	}{
+		{name: "success"},
+		{name: "failed", breakDB: func(provider *testKeyProvider) {
+			// 旧数据的密钥不存在，merge 中途失败
+			delete(provider.keys, 1)
+		}, wantErr: ErrEncryptionKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &testKeyProvider{current: 1, keys: map[uint32][]byte{1: bytes.Repeat([]byte("k"), 16)}}
+			options := defaultOptions()
+			options.MaxFileSize = 512
+			options.DataFileMergeThreshold = 0
+			options.KeyProvider = provider
+			db, err := Open(options)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer func() {
+				destroyDB(db)
+				_ = os.RemoveAll(db.getMergePath())
+			}()
+			for i := 0; i < 50; i++ {
+				_ = db.Put([]byte(fmt.Sprintf("key-%02d", i)), []byte("value"))
+			}
+			if tt.breakDB != nil {
+				tt.breakDB(provider)
+			}
+
+			if err = db.Merge(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Merge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if _, err = os.Stat(db.getMergePath()); !os.IsNotExist(err) {
+					t.Errorf("Merge() left the merge directory after an error")
+				}
+				return
+			}
+			// merge 目录的文件锁已经释放
+			lock := flock.New(filepath.Join(db.getMergePath(), fileLockName))
+			if locked, err := lock.TryLock(); !locked {
+				t.Errorf("merge directory is still locked, error = %v", err)
+			}
+			_ = lock.Unlock()
+		})
+	}
+}
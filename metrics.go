@@ -0,0 +1,190 @@
+package bitcask_go
+
+import (
+	"bufio"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 存储引擎上报的指标名称，时间单位为秒，数据量单位为 byte
+const (
+	MetricPutTotal       = "bitcask_put_total"
+	MetricPutDuration    = "bitcask_put_duration_seconds"
+	MetricGetTotal       = "bitcask_get_total"
+	MetricGetDuration    = "bitcask_get_duration_seconds"
+	MetricDeleteTotal    = "bitcask_delete_total"
+	MetricDeleteDuration = "bitcask_delete_duration_seconds"
+	MetricBytesWritten   = "bitcask_written_bytes_total"
+	MetricSyncTotal      = "bitcask_sync_total"
+	MetricSyncDuration   = "bitcask_sync_duration_seconds"
+	MetricFileRotations  = "bitcask_file_rotations_total"
+	MetricMergeTotal     = "bitcask_merge_total"
+	MetricMergeDuration  = "bitcask_merge_duration_seconds"
+	MetricMergeReclaimed = "bitcask_merge_reclaimed_bytes_total"
+	MetricIndexKeys      = "bitcask_index_keys"
+)
+
+// metricHelp 指标的说明，Prometheus 格式中作为 HELP 输出
+var metricHelp = map[string]string{
+	MetricPutTotal:       "Number of Put operations.",
+	MetricPutDuration:    "Latency of Put operations in seconds.",
+	MetricGetTotal:       "Number of Get operations.",
+	MetricGetDuration:    "Latency of Get operations in seconds.",
+	MetricDeleteTotal:    "Number of Delete operations.",
+	MetricDeleteDuration: "Latency of Delete operations in seconds.",
+	MetricBytesWritten:   "Bytes appended to data and blob files.",
+	MetricSyncTotal:      "Number of fsync calls on the active files.",
+	MetricSyncDuration:   "Latency of fsync calls in seconds.",
+	MetricFileRotations:  "Number of active data file rotations.",
+	MetricMergeTotal:     "Number of merge runs.",
+	MetricMergeDuration:  "Duration of merge runs in seconds.",
+	MetricMergeReclaimed: "Bytes reclaimed by merge runs.",
+	MetricIndexKeys:      "Number of keys in the default namespace index.",
+}
+
+// MetricsSink 指标收集接口，可以通过 Options.Metrics 接入自定义的监控系统
+// 方法会在持有数据库锁的情况下调用，实现需要是并发安全的并且尽快返回
+type MetricsSink interface {
+	// IncrCounter 累加计数器
+	IncrCounter(name string, delta uint64)
+	// ObserveHistogram 记录一次观测值，例如操作耗时
+	ObserveHistogram(name string, value float64)
+	// SetGauge 设置当前值
+	SetGauge(name string, value float64)
+}
+
+func (db *DB) incrCounter(name string, delta uint64) {
+	if db.options.Metrics != nil {
+		db.options.Metrics.IncrCounter(name, delta)
+	}
+}
+
+// observeIndexSize 更新默认命名空间索引中 key 的数量，需要持有 db.mu
+func (db *DB) observeIndexSize() {
+	if db.options.Metrics != nil {
+		db.options.Metrics.SetGauge(MetricIndexKeys, float64(db.index.Size()))
+	}
+}
+
+// observeOperation 记录一次操作的次数和从 start 开始的耗时
+func (db *DB) observeOperation(counter, histogram string, start time.Time) {
+	if db.options.Metrics != nil {
+		db.options.Metrics.IncrCounter(counter, 1)
+		db.options.Metrics.ObserveHistogram(histogram, time.Since(start).Seconds())
+	}
+}
+
+// DefaultHistogramBuckets PrometheusSink 默认的直方图分桶，单位秒，覆盖从 10 微秒到 10 秒的耗时
+var DefaultHistogramBuckets = []float64{
+	0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005,
+	0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+// PrometheusSink 在内存中聚合指标，并以 Prometheus 文本格式输出，可以直接挂载为 HTTP 服务的 /metrics
+type PrometheusSink struct {
+	mu         sync.Mutex
+	buckets    []float64
+	counters   map[string]uint64
+	gauges     map[string]float64
+	histograms map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // 每个分桶中的观测次数，不累加
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheusSink 创建 PrometheusSink，buckets 为直方图分桶的上界，为空时使用 DefaultHistogramBuckets
+func NewPrometheusSink(buckets ...float64) *PrometheusSink {
+	if len(buckets) == 0 {
+		buckets = DefaultHistogramBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &PrometheusSink{
+		buckets:    buckets,
+		counters:   make(map[string]uint64),
+		gauges:     make(map[string]float64),
+		histograms: make(map[string]*histogram),
+	}
+}
+
+func (s *PrometheusSink) IncrCounter(name string, delta uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[name] += delta
+}
+
+func (s *PrometheusSink) ObserveHistogram(name string, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.histograms[name]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(s.buckets))}
+		s.histograms[name] = h
+	}
+	if i := sort.SearchFloat64s(s.buckets, value); i < len(s.buckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+func (s *PrometheusSink) SetGauge(name string, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gauges[name] = value
+}
+
+// ServeHTTP 以 Prometheus 文本格式输出所有指标，指标按照名称排序
+func (s *PrometheusSink) ServeHTTP(writer http.ResponseWriter, _ *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w := bufio.NewWriter(writer)
+	defer func() { _ = w.Flush() }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names = make([]string, 0, len(s.counters)+len(s.gauges)+len(s.histograms))
+	for name := range s.counters {
+		names = append(names, name)
+	}
+	for name := range s.gauges {
+		names = append(names, name)
+	}
+	for name := range s.histograms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if help, ok := metricHelp[name]; ok {
+			_, _ = w.WriteString("# HELP " + name + " " + help + "\n")
+		}
+		if value, ok := s.counters[name]; ok {
+			_, _ = w.WriteString("# TYPE " + name + " counter\n")
+			_, _ = w.WriteString(name + " " + strconv.FormatUint(value, 10) + "\n")
+		} else if value, ok := s.gauges[name]; ok {
+			_, _ = w.WriteString("# TYPE " + name + " gauge\n")
+			_, _ = w.WriteString(name + " " + formatFloat(value) + "\n")
+		} else {
+			h := s.histograms[name]
+			_, _ = w.WriteString("# TYPE " + name + " histogram\n")
+			var cumulative uint64
+			for i, bound := range s.buckets {
+				cumulative += h.counts[i]
+				_, _ = w.WriteString(name + `_bucket{le="` + formatFloat(bound) + `"} ` + strconv.FormatUint(cumulative, 10) + "\n")
+			}
+			_, _ = w.WriteString(name + `_bucket{le="+Inf"} ` + strconv.FormatUint(h.count, 10) + "\n")
+			_, _ = w.WriteString(name + "_sum " + formatFloat(h.sum) + "\n")
+			_, _ = w.WriteString(name + "_count " + strconv.FormatUint(h.count, 10) + "\n")
+		}
+	}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,107 @@
+package bitcask_go
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDB_Metrics(t *testing.T) {
+	options := defaultOptions()
+	options.MaxFileSize = 512
+	options.DataFileMergeThreshold = 0
+	sink := NewPrometheusSink()
+	options.Metrics = sink
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() {
+		destroyDB(db)
+		_ = os.RemoveAll(db.getMergePath())
+	}()
+
+	for i := 0; i < 20; i++ {
+		_ = db.Put([]byte(fmt.Sprintf("key-%02d", i)), []byte("value"))
+	}
+	for i := 0; i < 5; i++ {
+		_, _ = db.Get([]byte(fmt.Sprintf("key-%02d", i)))
+		_ = db.Delete([]byte(fmt.Sprintf("key-%02d", i)))
+	}
+	_ = db.Sync()
+	if err = db.Merge(); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	sink.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("ServeHTTP() content type = %s", recorder.Header().Get("Content-Type"))
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "put counter", want: "bitcask_put_total 20\n"},
+		{name: "get counter", want: "bitcask_get_total 5\n"},
+		{name: "delete counter", want: "bitcask_delete_total 5\n"},
+		{name: "put histogram", want: `bitcask_put_duration_seconds_bucket{le="+Inf"} 20` + "\n"},
+		{name: "put histogram count", want: "bitcask_put_duration_seconds_count 20\n"},
+		{name: "counter type", want: "# TYPE bitcask_put_total counter\n"},
+		{name: "histogram type", want: "# TYPE bitcask_sync_duration_seconds histogram\n"},
+		{name: "index size", want: "# TYPE bitcask_index_keys gauge\nbitcask_index_keys 15\n"},
+		{name: "merge runs", want: "bitcask_merge_total 1\n"},
+		{name: "merge reclaimed", want: "bitcask_merge_reclaimed_bytes_total "},
+		{name: "rotations", want: "bitcask_file_rotations_total "},
+		{name: "bytes written", want: "bitcask_written_bytes_total "},
+		{name: "help", want: "# HELP bitcask_put_total Number of Put operations.\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("ServeHTTP() output does not contain %q:\n%s", tt.want, body)
+			}
+		})
+	}
+}
+
+func TestPrometheusSink_histogram(t *testing.T) {
+	sink := NewPrometheusSink(1, 0.1)
+	for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+		sink.ObserveHistogram("latency", v)
+	}
+	recorder := httptest.NewRecorder()
+	sink.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	want := `# TYPE latency histogram
+latency_bucket{le="0.1"} 2
+latency_bucket{le="1"} 3
+latency_bucket{le="+Inf"} 4
+latency_sum 2.65
+latency_count 4
+`
+	if got := recorder.Body.String(); got != want {
+		t.Errorf("ServeHTTP() = %q, want %q", got, want)
+	}
+}
+
+func TestDB_Stat_blobSizeFailed(t *testing.T) {
+	options := defaultOptions()
+	options.LargeValueThreshold = 16
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+	_ = db.Put([]byte("key"), []byte("a value stored in the blob file"))
+	// 关闭 blob 文件之后无法获取文件大小
+	_ = db.activeBlobFile.IOManager.Close()
+
+	stat := db.Stat()
+	if stat.StatError == "" || stat.KeyNum != 1 {
+		t.Errorf("Stat() = %+v", stat)
+	}
+}
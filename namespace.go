@@ -0,0 +1,191 @@
+package bitcask_go
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/xiecang/bitcask/data"
+	"github.com/xiecang/bitcask/index"
+	"time"
+)
+
+// Namespace 命名空间
+// 所有命名空间共享同一组数据文件，每个命名空间拥有独立的内存索引，不同命名空间中相同的 key 互不影响
+type Namespace struct {
+	db              *DB
+	name            []byte
+	index           index.Indexer // 命名空间的内存索引
+	reclaimableSize int64         // 命名空间中可以回收的数据大小
+}
+
+// NamespaceStat 命名空间的统计信息
+type NamespaceStat struct {
+	KeyNum          uint  // 命名空间中 key 的数量
+	ReclaimableSize int64 // 命名空间中可以回收的数据大小，单位字节
+}
+
+// Namespace 获取名称为 name 的命名空间，不存在时创建
+func (db *DB) Namespace(name string) (*Namespace, error) {
+	if len(name) == 0 {
+		return nil, ErrNamespaceIsEmpty
+	}
+	if db.options.IndexType == BPlusTree {
+		// B+ 树索引持久化在单个文件中，暂不支持多个索引
+		return nil, ErrNamespaceNotSupported
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.namespaceOf([]byte(name)), nil
+}
+
+// DropNamespace 删除命名空间中的所有数据
+// 删除之后命名空间仍然可以继续使用，数据占用的磁盘空间在下一次 Merge 之后释放
+func (db *DB) DropNamespace(name string) error {
+	if len(name) == 0 {
+		return ErrNamespaceIsEmpty
+	}
+
+	db.lockForWrite()
+	defer db.mu.Unlock()
+	ns, ok := db.namespaces[name]
+	if !ok {
+		return nil
+	}
+
+	record := data.LogRecord{
+		Key:       logRecordKeyWithSeq(nil, nonTransactionSeqId),
+		Type:      data.LogRecordTypeNamespaceDrop,
+		Namespace: ns.name,
+	}
+	pos, err := db.appendLogRecord(&record)
+	if err != nil {
+		return err
+	}
+	db.discardPos(ns, pos)
+	db.resetNamespace(ns)
+	return nil
+}
+
+// namespaceOf 获取名称为 name 的命名空间，不存在时创建，name 为空时返回 nil 表示默认命名空间
+// 需要持有 db.mu
+func (db *DB) namespaceOf(name []byte) *Namespace {
+	if len(name) == 0 {
+		return nil
+	}
+	if ns, ok := db.namespaces[string(name)]; ok {
+		return ns
+	}
+	ns := &Namespace{
+		db:    db,
+		name:  name,
+		index: index.NewIndexer(db.options.IndexType, db.options.DirPath, db.options.SyncWrites),
+	}
+	db.namespaces[string(name)] = ns
+	return ns
+}
+
+// lookupNamespaceIndex 获取名称为 name 的命名空间的内存索引，命名空间不存在时返回 nil
+func (db *DB) lookupNamespaceIndex(name []byte) index.Indexer {
+	if len(name) == 0 {
+		return db.index
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if ns, ok := db.namespaces[string(name)]; ok {
+		return ns.index
+	}
+	return nil
+}
+
+// resetNamespace 清空命名空间的内存索引，原有数据全部变为可回收，需要持有 db.mu
+func (db *DB) resetNamespace(ns *Namespace) {
+	iterator := ns.index.Iterator(false)
+	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		db.discardPos(ns, iterator.Value())
+	}
+	iterator.Close()
+	_ = ns.index.Close()
+	ns.index = index.NewIndexer(db.options.IndexType, db.options.DirPath, db.options.SyncWrites)
+}
+
+// indexOf 返回命名空间的内存索引，ns 为 nil 时返回默认命名空间的内存索引
+func (db *DB) indexOf(ns *Namespace) index.Indexer {
+	if ns == nil {
+		return db.index
+	}
+	return ns.index
+}
+
+// addReclaimableSize 累加可回收的数据大小
+func (db *DB) addReclaimableSize(ns *Namespace, size int64) {
+	db.reclaimableSize += size
+	if ns != nil {
+		ns.reclaimableSize += size
+	}
+}
+
+// namespaceName 返回命名空间的名称，默认命名空间的名称为空
+func namespaceName(ns *Namespace) []byte {
+	if ns == nil {
+		return nil
+	}
+	return ns.name
+}
+
+// Name 返回命名空间的名称
+func (ns *Namespace) Name() string {
+	return string(ns.name)
+}
+
+// Put 写入 key-value 数据到命名空间中
+func (ns *Namespace) Put(key []byte, value []byte) error {
+	return ns.db.put(ns, key, value, 0)
+}
+
+// Get 根据 key 读取命名空间中的数据
+func (ns *Namespace) Get(key []byte) ([]byte, error) {
+	db := ns.db
+	defer db.observeOperation(MetricGetTotal, MetricGetDuration, time.Now())
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.getFromIndex(ns.index, key)
+}
+
+// Delete 删除命名空间中 key 对应的数据
+func (ns *Namespace) Delete(key []byte) error {
+	return ns.db.delete(ns, key)
+}
+
+// NewIterator 创建命名空间的迭代器
+func (ns *Namespace) NewIterator(opt *IteratorOption) *Iterator {
+	ns.db.mu.RLock()
+	defer ns.db.mu.RUnlock()
+	return ns.db.newIterator(ns.index.Iterator(opt.Reverse), opt)
+}
+
+// NewWriteBatch 创建写入命名空间的批量写
+func (ns *Namespace) NewWriteBatch(options WriteBatchOption) *WriteBatch {
+	return ns.db.NewWriteBatch(options).In(ns)
+}
+
+// Watch 订阅命名空间中前缀为 prefix 的 key 的变更
+func (ns *Namespace) Watch(ctx context.Context, prefix []byte) <-chan []WatchEvent {
+	return ns.db.watch(ctx, ns, prefix, DefaultWatchOptions)
+}
+
+// Stat 返回命名空间的统计信息
+func (ns *Namespace) Stat() *NamespaceStat {
+	ns.db.mu.RLock()
+	defer ns.db.mu.RUnlock()
+	return &NamespaceStat{
+		KeyNum:          uint(ns.index.Size()),
+		ReclaimableSize: ns.reclaimableSize,
+	}
+}
+
+// pendingWriteKey 批量写中暂存数据的 key，由命名空间和 key 共同确定
+func pendingWriteKey(namespace, key []byte) string {
+	buf := binary.AppendUvarint(nil, uint64(len(namespace)))
+	buf = append(buf, namespace...)
+	return string(append(buf, key...))
+}
@@ -0,0 +1,199 @@
+package bitcask_go
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDB_Namespace(t *testing.T) {
+	tests := []struct {
+		name    string
+		ns      string
+		index   IndexType
+		wantErr error
+	}{
+		{name: "btree", ns: "users", index: BTree},
+		{name: "art", ns: "users", index: ART},
+		{name: "empty name", ns: "", index: BTree, wantErr: ErrNamespaceIsEmpty},
+		{name: "bplus tree", ns: "users", index: BPlusTree, wantErr: ErrNamespaceNotSupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.IndexType = tt.index
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer destroyDB(db)
+
+			ns, err := db.Namespace(tt.ns)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Namespace() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			// 不同命名空间中相同的 key 互不影响
+			_ = db.Put([]byte("key"), []byte("default"))
+			if err = ns.Put([]byte("key"), []byte("ns")); err != nil {
+				t.Errorf("Put() error = %v", err)
+			}
+			if got, _ := db.Get([]byte("key")); string(got) != "default" {
+				t.Errorf("DB.Get() = %s, want default", got)
+			}
+			if got, _ := ns.Get([]byte("key")); string(got) != "ns" {
+				t.Errorf("Namespace.Get() = %s, want ns", got)
+			}
+			if err = ns.Delete([]byte("key")); err != nil {
+				t.Errorf("Delete() error = %v", err)
+			}
+			if _, err = ns.Get([]byte("key")); !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("Namespace.Get() error = %v, want %v", err, ErrKeyNotFound)
+			}
+			if got, _ := db.Get([]byte("key")); string(got) != "default" {
+				t.Errorf("DB.Get() = %s, want default", got)
+			}
+			if stat := ns.Stat(); stat.KeyNum != 0 || stat.ReclaimableSize == 0 {
+				t.Errorf("Stat() = %+v", stat)
+			}
+		})
+	}
+}
+
+func TestNamespace_reopen(t *testing.T) {
+	options := defaultOptions()
+	db, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer func() { destroyDB(db) }()
+
+	users, _ := db.Namespace("users")
+	orders, _ := db.Namespace("orders")
+	_ = users.Put([]byte("1"), []byte("alice"))
+	_ = users.Put([]byte("2"), []byte("bob"))
+	_ = orders.Put([]byte("1"), []byte("order"))
+	// 一个批量写可以原子地写入多个命名空间
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put([]byte("1"), []byte("default"))
+	_ = wb.In(users).Put([]byte("3"), []byte("carol"))
+	_ = wb.In(orders).Delete([]byte("1"))
+	if err = wb.Commit(); err != nil {
+		t.Errorf("Commit() error = %v", err)
+	}
+	if err = db.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	db, err = Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	users, _ = db.Namespace("users")
+	orders, _ = db.Namespace("orders")
+	if got, _ := db.Get([]byte("1")); string(got) != "default" {
+		t.Errorf("DB.Get() = %s, want default", got)
+	}
+	var keys []string
+	iter := users.NewIterator(defaultIteratorOption())
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	iter.Close()
+	if len(keys) != 3 || keys[0] != "1" || keys[2] != "3" {
+		t.Errorf("NewIterator() keys = %v", keys)
+	}
+	if stat := orders.Stat(); stat.KeyNum != 0 {
+		t.Errorf("orders Stat() = %+v, want empty", stat)
+	}
+}
+
+func TestDB_DropNamespace(t *testing.T) {
+	options := defaultOptions()
+	db, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer func() { destroyDB(db) }()
+
+	users, _ := db.Namespace("users")
+	_ = db.Put([]byte("key"), []byte("default"))
+	_ = users.Put([]byte("key"), []byte("user"))
+	_ = users.Put([]byte("key2"), []byte("user2"))
+	if err = db.DropNamespace("users"); err != nil {
+		t.Errorf("DropNamespace() error = %v", err)
+	}
+	if _, err = users.Get([]byte("key")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get() after drop error = %v, want %v", err, ErrKeyNotFound)
+	}
+	// 删除之后命名空间可以继续使用
+	_ = users.Put([]byte("key3"), []byte("user3"))
+	if stat := users.Stat(); stat.KeyNum != 1 || stat.ReclaimableSize == 0 {
+		t.Errorf("Stat() = %+v", stat)
+	}
+
+	// 重启之后删除仍然生效
+	if err = db.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if db, err = Open(options); err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	users, _ = db.Namespace("users")
+	if stat := users.Stat(); stat.KeyNum != 1 {
+		t.Errorf("Stat() after reopen = %+v, want 1 key", stat)
+	}
+
+	// merge 之后被删除的数据不再保留
+	if err = db.Merge(); err != nil {
+		t.Errorf("Merge() error = %v", err)
+	}
+	if err = db.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if db, err = Open(options); err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	users, _ = db.Namespace("users")
+	if stat := users.Stat(); stat.KeyNum != 1 || stat.ReclaimableSize != 0 {
+		t.Errorf("Stat() after merge = %+v", stat)
+	}
+	if got, _ := users.Get([]byte("key3")); string(got) != "user3" {
+		t.Errorf("Get() after merge = %s, want user3", got)
+	}
+	if got, _ := db.Get([]byte("key")); string(got) != "default" {
+		t.Errorf("DB.Get() after merge = %s, want default", got)
+	}
+}
+
+func TestNamespace_Watch(t *testing.T) {
+	db, err := Open(defaultOptions())
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer destroyDB(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	users, _ := db.Namespace("users")
+	ch := users.Watch(ctx, nil)
+
+	_ = db.Put([]byte("key"), []byte("default"))
+	_ = users.Put([]byte("key"), []byte("user"))
+
+	batches := receiveWatchEvents(ch, 2)
+	if len(batches) != 1 || string(batches[0][0].Value) != "user" || string(batches[0][0].Namespace) != "users" {
+		t.Errorf("Watch() batches = %+v", batches)
+	}
+}
@@ -3,6 +3,8 @@ package bitcask_go
 import (
 	"os"
 	"path/filepath"
+	"runtime"
+	"time"
 )
 
 type Options struct {
@@ -12,13 +14,51 @@ type Options struct {
 
 	SyncWrites bool // 是否同步写入，true 时每次写入都会持久化到磁盘当中
 
+	DisableGroupCommit bool // 同步写入时是否关闭组提交，关闭后每次写入都单独执行一次 sync
+
 	BytesPerSync uint // 每写入指定字节数后同步到磁盘
 
 	IndexType IndexType // 索引类型
 
 	MMapAtStartup bool // 是否在启动时将索引文件映射到内存当中
 
+	LoadConcurrency int // 启动时并发读取数据文件重建索引的协程数量，为 0 或 1 时依次读取
+
+	RecoveryMode RecoveryMode // 启动时遇到损坏的记录的处理方式，默认打开失败
+
+	RecoveryCallback func(event RecoveryEvent) // 启动时每次截断或跳过损坏的数据之后的回调，为 nil 时输出到日志
+
 	DataFileMergeThreshold float32 // 数据文件合并阈值, 无效数据文件占总数据文件大小的比例超过该阈值时触发合并
+
+	ReadOnly bool // 是否以只读模式打开，只读模式下不加文件锁，可以和写入方以及其他只读实例同时打开同一个目录
+
+	AutoMergeInterval time.Duration // 后台检查是否需要自动 merge 的时间间隔，为 0 时不启用自动 merge
+
+	AutoMergeWindow string // 允许自动 merge 的时间段，例如 "02:00-04:00"，为空时不限制
+
+	AutoMergeCallback func(err error) // 每次自动 merge 完成之后的回调，err 为 nil 表示 merge 成功
+
+	Compression CompressionType // value 的压缩算法，默认不压缩
+
+	Compressor Compressor // 自定义的压缩算法，设置之后优先于 Compression
+
+	CompressionThreshold int // value 小于该大小时不压缩，单位 byte
+
+	EncryptionKey []byte // 加密密钥，长度为 16、24 或 32 字节，设置之后使用 AES-GCM 加密数据文件中的 key 和 value，不支持 BPlusTree 索引
+
+	KeyProvider KeyProvider // 加密密钥的提供者，用于密钥轮换，设置之后优先于 EncryptionKey
+
+	LargeValueThreshold int // value 大于等于该大小时单独存储到 blob 文件中，数据文件只保留 value 的位置，为 0 时不启用
+
+	BlobGCThreshold float32 // blob 文件回收阈值，blob 文件中可回收数据占文件大小的比例达到该阈值时回收
+
+	BlobGCInterval time.Duration // 后台回收 blob 文件的时间间隔，为 0 时不启用后台回收
+
+	BlobGCCallback func(err error) // 每次后台回收 blob 文件之后的回调，err 为 nil 表示回收成功
+
+	Metrics MetricsSink // 指标收集，例如操作次数和耗时，为 nil 时不收集
+
+	Logger Logger // 结构化日志，可以使用 *slog.Logger，为 nil 时只将警告和错误输出到标准库 log
 }
 
 type IteratorOption struct {
@@ -32,6 +72,40 @@ type WriteBatchOption struct {
 
 	SyncWrites bool // 是否同步写入，true 时每次写入都会持久化到磁盘当中
 }
+
+// BackupStreamOption 流式备份配置项
+type BackupStreamOption struct {
+	Gzip bool // 是否使用 gzip 压缩备份数据流
+}
+
+// DumpFormat 逻辑导出数据的格式
+type DumpFormat int8
+
+const (
+	DumpJSONLines DumpFormat = iota // 每行一个 JSON 对象，key、value 和命名空间使用 base64 编码
+	DumpBinary                      // 长度前缀的二进制格式，体积更小
+)
+
+// ExportOption 逻辑导出配置项
+type ExportOption struct {
+	Format DumpFormat // 导出数据的格式
+
+	Prefix []byte // 只导出前缀为 Prefix 的 key，默认为空，即不限制
+
+	Start []byte // 只导出大于等于 Start 的 key，默认为空，即不限制
+
+	End []byte // 只导出小于 End 的 key，默认为空，即不限制
+}
+
+// ImportOption 逻辑导入配置项
+type ImportOption struct {
+	Format DumpFormat // 导入数据的格式，需要与导出时一致
+
+	BatchSize uint // 每个 WriteBatch 写入的 key 数量
+
+	Progress func(imported uint64) // 每提交一个批次之后的回调，imported 为已经导入的 key 数量
+}
+
 type IndexType = int8
 
 const (
@@ -47,10 +121,25 @@ var DefaultOptions = Options{
 	BytesPerSync:           0,
 	IndexType:              BTree,
 	MMapAtStartup:          true,
+	LoadConcurrency:        runtime.NumCPU(),
 	DataFileMergeThreshold: 0.5,
+	BlobGCThreshold:        0.5,
 }
 
 var DefaultWriteBatchOptions = WriteBatchOption{
 	MaxBatchSize: 10000,
 	SyncWrites:   true,
 }
+
+var DefaultBackupStreamOptions = BackupStreamOption{
+	Gzip: true,
+}
+
+var DefaultExportOptions = ExportOption{
+	Format: DumpJSONLines,
+}
+
+var DefaultImportOptions = ImportOption{
+	Format:    DumpJSONLines,
+	BatchSize: 1000,
+}
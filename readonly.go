@@ -0,0 +1,128 @@
+package bitcask_go
+
+import (
+	"github.com/xiecang/bitcask/data"
+	"github.com/xiecang/bitcask/fio"
+	"github.com/xiecang/bitcask/index"
+	"os"
+	"sync/atomic"
+)
+
+// Refresh 只读模式下读取写入方在打开之后追加的数据，非只读模式下直接返回
+// 如果写入方在此期间应用了 merge 的结果，数据文件已经被替换，会重新加载全部索引
+// 此时旧的快照和迭代器中的位置会失效，存在尚未释放的快照或者尚未关闭的迭代器时返回 ErrReloadWithOpenReaders
+func (db *DB) Refresh() error {
+	if !db.options.ReadOnly {
+		return nil
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if mergeFinishedTime(db.options.DirPath) != db.mergeFinishedTime {
+		return db.reload()
+	}
+
+	if err := db.loadBlobFiles(); err != nil {
+		return err
+	}
+	fileIds, err := dataFileIds(db.options.DirPath)
+	if err != nil {
+		return err
+	}
+	// 从当前活跃文件上次读取到的位置开始，依次读取新的数据文件
+	var refreshIds []int
+	for _, fid := range fileIds {
+		var fileId = uint32(fid)
+		if db.activeFile != nil && fileId < db.activeFile.Id {
+			continue
+		}
+		if db.activeFile == nil || fileId > db.activeFile.Id {
+			file, err := data.OpenFile(db.options.DirPath, fileId, fio.FIOStandar)
+			if err != nil {
+				return err
+			}
+			if db.activeFile != nil {
+				db.olderFiles[db.activeFile.Id] = db.activeFile
+			}
+			db.activeFile = file
+		}
+		refreshIds = append(refreshIds, fid)
+	}
+	return db.loadIndexFromDataFiles(refreshIds)
+}
+
+// reload 重新打开数据目录并替换当前的数据文件和索引，需要持有 db.mu
+func (db *DB) reload() error {
+	if len(db.snapshots) > 0 || atomic.LoadInt32(&db.iterators) > 0 {
+		return ErrReloadWithOpenReaders
+	}
+	fresh, err := Open(db.options)
+	if err != nil {
+		return err
+	}
+
+	// 先替换为新实例的数据文件和索引，再关闭旧的，关闭失败时当前实例仍然可以继续使用
+	var oldFiles = make([]*data.File, 0, len(db.olderFiles)+len(db.blobFiles)+1)
+	if db.activeFile != nil {
+		oldFiles = append(oldFiles, db.activeFile)
+	}
+	for _, file := range db.olderFiles {
+		oldFiles = append(oldFiles, file)
+	}
+	for _, file := range db.blobFiles {
+		oldFiles = append(oldFiles, file)
+	}
+	var oldIndexes = []index.Indexer{db.index}
+
+	db.activeFile = fresh.activeFile
+	db.olderFiles = fresh.olderFiles
+	db.index = fresh.index
+	db.seqId = fresh.seqId
+	db.reclaimableSize = fresh.reclaimableSize
+	db.pendingTxnRecords = fresh.pendingTxnRecords
+	db.mergeFinishedTime = fresh.mergeFinishedTime
+	db.activeBlobFile = fresh.activeBlobFile
+	db.blobFiles = fresh.blobFiles
+	db.blobRefs = fresh.blobRefs
+	db.blobGarbage = fresh.blobGarbage
+
+	// 已经获取的命名空间继续有效
+	for name, ns := range db.namespaces {
+		oldIndexes = append(oldIndexes, ns.index)
+		if freshNs, ok := fresh.namespaces[name]; ok {
+			ns.index = freshNs.index
+			ns.reclaimableSize = freshNs.reclaimableSize
+		} else {
+			ns.index = index.NewIndexer(db.options.IndexType, db.options.DirPath, db.options.SyncWrites)
+			ns.reclaimableSize = 0
+		}
+	}
+	for name, freshNs := range fresh.namespaces {
+		if _, ok := db.namespaces[name]; !ok {
+			freshNs.db = db
+			db.namespaces[name] = freshNs
+		}
+	}
+
+	// 关闭旧的索引和数据文件，新实例的资源已经全部转移到当前实例，不需要关闭
+	for _, indexer := range oldIndexes {
+		if closeErr := indexer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	for _, file := range oldFiles {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// mergeFinishedTime 返回数据目录中 merge 完成文件的修改时间，文件不存在时返回 0
+func mergeFinishedTime(dirPath string) int64 {
+	info, err := os.Stat(data.MergeFinishedFileName(dirPath))
+	if err != nil {
+		return 0
+	}
+	return info.ModTime().UnixNano()
+}
@@ -0,0 +1,247 @@
+package bitcask_go
+
+import (
+	"errors"
+	"github.com/xiecang/bitcask/index"
+	"os"
+	"testing"
+)
+
+func TestDB_ReadOnly(t *testing.T) {
+	options := defaultOptions()
+	options.MaxFileSize = 128
+	writer, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer destroyDB(writer)
+	_ = writer.Put([]byte("key"), []byte("value"))
+
+	readOptions := options
+	readOptions.ReadOnly = true
+	reader, err := Open(readOptions)
+	if err != nil {
+		t.Errorf("Open() read only error = %v", err)
+		return
+	}
+	defer func() { _ = reader.Close() }()
+	// 多个只读实例可以同时打开
+	reader2, err := Open(readOptions)
+	if err != nil {
+		t.Errorf("Open() second read only error = %v", err)
+		return
+	}
+	_ = reader2.Close()
+
+	if got, _ := reader.Get([]byte("key")); string(got) != "value" {
+		t.Errorf("Get() = %s, want value", got)
+	}
+	if err = reader.Put([]byte("key"), []byte("value2")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Put() error = %v, want %v", err, ErrReadOnly)
+	}
+	if err = reader.Delete([]byte("key")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Delete() error = %v, want %v", err, ErrReadOnly)
+	}
+	if err = reader.Merge(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Merge() error = %v, want %v", err, ErrReadOnly)
+	}
+	if err = reader.NewWriteBatch(DefaultWriteBatchOptions).Put([]byte("key"), nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("WriteBatch.Put() error = %v, want %v", err, ErrReadOnly)
+	}
+
+	// 写入方追加的数据在 Refresh 之后可见，包括新的数据文件和批量写入
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		_ = writer.Put([]byte(key), []byte("value-"+key))
+	}
+	_ = writer.Delete([]byte("key"))
+	wb := writer.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put([]byte("f"), []byte("value-f"))
+	_ = wb.Commit()
+	if _, err = reader.Get([]byte("a")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get() before Refresh error = %v, want %v", err, ErrKeyNotFound)
+	}
+	if err = reader.Refresh(); err != nil {
+		t.Errorf("Refresh() error = %v", err)
+	}
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		if got, _ := reader.Get([]byte(key)); string(got) != "value-"+key {
+			t.Errorf("Get(%s) after Refresh = %s", key, got)
+		}
+	}
+	if _, err = reader.Get([]byte("key")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get() deleted key error = %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestDB_ReadOnly_noCreate(t *testing.T) {
+	options := defaultOptions()
+	options.ReadOnly = true
+	if _, err := Open(options); err == nil {
+		t.Errorf("Open() read only on missing dir should fail")
+	}
+	if _, err := os.Stat(options.DirPath); !os.IsNotExist(err) {
+		t.Errorf("Open() read only created dir, err = %v", err)
+	}
+
+	options.ReadOnly = false
+	db, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer destroyDB(db)
+	_ = db.Put([]byte("key"), []byte("value"))
+	_ = db.Close()
+	entries, _ := os.ReadDir(options.DirPath)
+
+	options.ReadOnly = true
+	reader, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() read only error = %v", err)
+		return
+	}
+	if err = reader.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	after, _ := os.ReadDir(options.DirPath)
+	if len(after) != len(entries) {
+		t.Errorf("read only Open() created files, before %v, after %v", len(entries), len(after))
+	}
+	if _, err = os.Stat(options.DirPath + mergeDirName); !os.IsNotExist(err) {
+		t.Errorf("read only Open() created merge dir")
+	}
+}
+
+func TestDB_Refresh_afterMerge(t *testing.T) {
+	options := defaultOptions()
+	writer, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer func() { destroyDB(writer) }()
+	_ = writer.Put([]byte("key"), []byte("value"))
+	_ = writer.Put([]byte("key"), []byte("value2"))
+	_ = writer.Put([]byte("key2"), []byte("value"))
+	_ = writer.Delete([]byte("key2"))
+
+	readOptions := options
+	readOptions.ReadOnly = true
+	reader, err := Open(readOptions)
+	if err != nil {
+		t.Errorf("Open() read only error = %v", err)
+		return
+	}
+	defer func() { _ = reader.Close() }()
+
+	// 写入方 merge 并重启之后，数据文件被替换，Refresh 重新加载索引
+	if err = writer.Merge(); err != nil {
+		t.Errorf("Merge() error = %v", err)
+	}
+	_ = writer.Close()
+	if writer, err = Open(options); err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	_ = writer.Put([]byte("key3"), []byte("value3"))
+
+	// 快照和迭代器仍然引用旧的数据文件时拒绝重新加载
+	snapshot := reader.Snapshot()
+	iterator := reader.NewIterator(defaultIteratorOption())
+	if err = reader.Refresh(); err != ErrReloadWithOpenReaders {
+		t.Errorf("Refresh() with open snapshot error = %v, want %v", err, ErrReloadWithOpenReaders)
+	}
+	if got, _ := snapshot.Get([]byte("key")); string(got) != "value2" {
+		t.Errorf("snapshot Get() = %s, want value2", got)
+	}
+	snapshot.Release()
+	if err = reader.Refresh(); err != ErrReloadWithOpenReaders {
+		t.Errorf("Refresh() with open iterator error = %v, want %v", err, ErrReloadWithOpenReaders)
+	}
+	iterator.Close()
+	iterator.Close()
+
+	if err = reader.Refresh(); err != nil {
+		t.Errorf("Refresh() error = %v", err)
+	}
+	if got, _ := reader.Get([]byte("key")); string(got) != "value2" {
+		t.Errorf("Get() after merge = %s, want value2", got)
+	}
+	if got, _ := reader.Get([]byte("key3")); string(got) != "value3" {
+		t.Errorf("Get() after merge = %s, want value3", got)
+	}
+	if _, err = reader.Get([]byte("key2")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get() deleted key error = %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+// closeCountingIndexer 记录 Close 调用次数的索引
+type closeCountingIndexer struct {
+	index.Indexer
+	closed int
+}
+
+func (i *closeCountingIndexer) Close() error {
+	i.closed++
+	return i.Indexer.Close()
+}
+
+func TestDB_Refresh_closesReplacedIndexes(t *testing.T) {
+	options := defaultOptions()
+	writer, err := Open(options)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer func() { destroyDB(writer) }()
+	ns, err := writer.Namespace("ns")
+	if err != nil {
+		t.Errorf("Namespace() error = %v", err)
+		return
+	}
+	_ = writer.Put([]byte("key"), []byte("value"))
+	_ = ns.Put([]byte("key"), []byte("value"))
+
+	readOptions := options
+	readOptions.ReadOnly = true
+	reader, err := Open(readOptions)
+	if err != nil {
+		t.Errorf("Open() read only error = %v", err)
+		return
+	}
+	defer func() { _ = reader.Close() }()
+	readerNs, err := reader.Namespace("ns")
+	if err != nil {
+		t.Errorf("read only Namespace() error = %v", err)
+		return
+	}
+	oldIndex := &closeCountingIndexer{Indexer: reader.index}
+	reader.index = oldIndex
+	oldNsIndex := &closeCountingIndexer{Indexer: readerNs.index}
+	readerNs.index = oldNsIndex
+
+	// 写入方 merge 并重启之后 Refresh 重新加载索引
+	_ = ns.Put([]byte("key2"), []byte("value2"))
+	if err = writer.Merge(); err != nil {
+		t.Errorf("Merge() error = %v", err)
+	}
+	_ = writer.Close()
+	if writer, err = Open(options); err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	if err = reader.Refresh(); err != nil {
+		t.Errorf("Refresh() error = %v", err)
+	}
+	// 被替换的索引已经关闭，之后的读取使用新的索引
+	if oldIndex.closed != 1 || oldNsIndex.closed != 1 {
+		t.Errorf("replaced indexes closed = %d, %d, want 1, 1", oldIndex.closed, oldNsIndex.closed)
+	}
+	if got, _ := readerNs.Get([]byte("key2")); string(got) != "value2" {
+		t.Errorf("namespace Get() after Refresh = %s, want value2", got)
+	}
+	if got, _ := reader.Get([]byte("key")); string(got) != "value" {
+		t.Errorf("Get() after Refresh = %s, want value", got)
+	}
+}
@@ -0,0 +1,97 @@
+package bitcask_go
+
+import (
+	"github.com/xiecang/bitcask/data"
+	"os"
+)
+
+// RecoveryMode 启动时遇到损坏的记录的处理方式
+type RecoveryMode int8
+
+const (
+	RecoveryStrict       RecoveryMode = iota // 遇到损坏的记录时打开失败
+	RecoveryTruncateTail                     // 截断活跃文件末尾损坏的记录，例如写入过程中进程崩溃留下的不完整记录
+	RecoverySkipCorrupt                      // 在 RecoveryTruncateTail 的基础上，跳过旧数据文件中损坏的记录，从下一条有效的记录继续读取
+)
+
+// RecoveryAction 对损坏的数据执行的操作
+type RecoveryAction int8
+
+const (
+	RecoveryTruncated RecoveryAction = iota + 1 // 截断了文件末尾损坏的数据
+	RecoverySkipped                             // 跳过了文件中间损坏的数据
+)
+
+// RecoveryEvent 启动时的一次数据恢复
+type RecoveryEvent struct {
+	Action RecoveryAction
+	FileId uint32 // 数据文件 id
+	Offset int64  // 损坏数据在文件中的起始位置
+	Size   int64  // 被丢弃的数据大小，单位 byte
+	Err    error  // 读取损坏数据时的错误
+}
+
+// recoverCorruption 处理数据文件 offset 位置读取失败的记录，返回继续读取的位置以及执行的恢复操作
+// 无法按照 RecoveryMode 恢复时返回 cause
+func (db *DB) recoverCorruption(file *data.File, offset int64, cause error) (int64, *RecoveryEvent, error) {
+	var isActive = file.Id == db.activeFile.Id
+	var mode = db.options.RecoveryMode
+	if mode == RecoveryStrict || (mode == RecoveryTruncateTail && !isActive) {
+		return 0, nil, cause
+	}
+	fileSize, err := file.IOManager.Size()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var next = fileSize
+	if mode == RecoverySkipCorrupt {
+		next = resyncDataFile(file, offset, fileSize)
+	}
+	var event = &RecoveryEvent{
+		Action: RecoverySkipped,
+		FileId: file.Id,
+		Offset: offset,
+		Size:   next - offset,
+		Err:    cause,
+	}
+	if next == fileSize && isActive {
+		// 活跃文件之后没有有效的记录，截断之后从损坏的位置继续写入
+		event.Action = RecoveryTruncated
+		next = offset
+	}
+	return next, event, nil
+}
+
+// resyncDataFile 从 offset 之后逐字节查找下一条有效的记录，返回其位置，找不到时返回文件大小
+func resyncDataFile(file *data.File, offset, fileSize int64) int64 {
+	for next := offset + 1; next < fileSize; next++ {
+		if _, _, err := file.ReadLogRecord(next); err == nil {
+			return next
+		}
+	}
+	return fileSize
+}
+
+// applyRecovery 执行恢复操作并通知调用方，没有设置回调时输出到日志
+func (db *DB) applyRecovery(event *RecoveryEvent) error {
+	if event.Action == RecoveryTruncated && !db.options.ReadOnly {
+		if err := os.Truncate(data.GetFilePath(db.options.DirPath, event.FileId), event.Offset); err != nil {
+			return err
+		}
+	}
+	if db.options.RecoveryCallback != nil {
+		db.options.RecoveryCallback(*event)
+		return nil
+	}
+	var msg = "corrupt data skipped"
+	if event.Action == RecoveryTruncated {
+		msg = "corrupt data truncated"
+	}
+	db.logger().Warn(msg,
+		"file_id", event.FileId,
+		"offset", event.Offset,
+		"bytes", event.Size,
+		"error", event.Err)
+	return nil
+}
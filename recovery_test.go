@@ -0,0 +1,153 @@
+package bitcask_go
+
+import (
+	"fmt"
+	"github.com/xiecang/bitcask/data"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// appendGarbage 在活跃文件末尾追加一条不完整的记录，模拟写入过程中进程崩溃
+func appendGarbage(t *testing.T, dirPath string, fileId uint32) {
+	encoded, _ := data.EncodeLogRecord(&data.LogRecord{
+		Key:   logRecordKeyWithSeq([]byte("torn"), nonTransactionSeqId),
+		Value: []byte("torn-value"),
+	})
+	f, err := os.OpenFile(data.GetFilePath(dirPath, fileId), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	_, _ = f.Write(encoded[:len(encoded)-3])
+	_ = f.Close()
+}
+
+// corruptRecord 修改数据文件中 offset 位置的一个字节，并删除 hint 文件，使启动时扫描数据文件
+func corruptRecord(t *testing.T, dirPath string, fileId uint32, offset int64) {
+	f, err := os.OpenFile(data.GetFilePath(dirPath, fileId), os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	var b = make([]byte, 1)
+	_, _ = f.ReadAt(b, offset)
+	_, _ = f.WriteAt([]byte{b[0] ^ 0xff}, offset)
+	_ = f.Close()
+	hints, _ := filepath.Glob(filepath.Join(dirPath, "*"+data.HintFileNameSuffix))
+	for _, hint := range hints {
+		_ = os.Remove(hint)
+	}
+}
+
+func TestDB_RecoveryMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       RecoveryMode
+		mmap       bool
+		olderFile  bool // 损坏的是旧数据文件中的记录，否则是活跃文件末尾不完整的记录
+		wantErr    bool
+		wantAction RecoveryAction
+	}{
+		{name: "strict torn tail", mode: RecoveryStrict, wantErr: true},
+		{name: "truncate torn tail", mode: RecoveryTruncateTail, wantAction: RecoveryTruncated},
+		{name: "truncate torn tail mmap", mode: RecoveryTruncateTail, mmap: true, wantAction: RecoveryTruncated},
+		{name: "skip torn tail", mode: RecoverySkipCorrupt, wantAction: RecoveryTruncated},
+		{name: "strict older file", mode: RecoveryStrict, olderFile: true, wantErr: true},
+		{name: "truncate older file", mode: RecoveryTruncateTail, olderFile: true, wantErr: true},
+		{name: "skip older file", mode: RecoverySkipCorrupt, olderFile: true, wantAction: RecoverySkipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.MaxFileSize = 256
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer func() {
+				// 打开失败时 db 为 nil，同样需要清理数据目录
+				destroyDB(db)
+				_ = os.RemoveAll(options.DirPath)
+			}()
+			for i := 0; i < 30; i++ {
+				_ = db.Put([]byte(fmt.Sprintf("key-%02d", i)), []byte(fmt.Sprintf("value-%02d", i)))
+			}
+			// 第一个数据文件中第二条记录的位置
+			secondPos := db.index.Get([]byte("key-01"))
+			activeFileId := db.activeFile.Id
+			if err = db.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			var activeSize int64
+			if tt.olderFile {
+				corruptRecord(t, options.DirPath, secondPos.Fid, secondPos.Offset+int64(secondPos.Size)-1)
+			} else {
+				info, _ := os.Stat(data.GetFilePath(options.DirPath, activeFileId))
+				activeSize = info.Size()
+				appendGarbage(t, options.DirPath, activeFileId)
+			}
+
+			var events []RecoveryEvent
+			options.RecoveryMode = tt.mode
+			options.MMapAtStartup = tt.mmap
+			options.RecoveryCallback = func(event RecoveryEvent) {
+				events = append(events, event)
+			}
+			if db, err = Open(options); (err != nil) != tt.wantErr {
+				t.Errorf("Open() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if len(events) != 1 || events[0].Action != tt.wantAction || events[0].Size == 0 || events[0].Err == nil {
+				t.Errorf("RecoveryCallback() events = %+v", events)
+			}
+			if !tt.olderFile {
+				// 截断之后活跃文件恢复到崩溃之前的大小
+				info, _ := os.Stat(data.GetFilePath(options.DirPath, activeFileId))
+				if info.Size() != activeSize {
+					t.Errorf("active file size = %v, want %v", info.Size(), activeSize)
+				}
+			}
+			for i := 0; i < 30; i++ {
+				got, err := db.Get([]byte(fmt.Sprintf("key-%02d", i)))
+				if tt.olderFile && i == 1 {
+					if err != ErrKeyNotFound {
+						t.Errorf("Get(key-01) error = %v, want %v", err, ErrKeyNotFound)
+					}
+					continue
+				}
+				if string(got) != fmt.Sprintf("value-%02d", i) {
+					t.Errorf("Get(key-%02d) = %s, error = %v", i, got, err)
+				}
+			}
+
+			// 恢复之后可以继续写入，重启不再需要恢复
+			_ = db.Put([]byte("key-new"), []byte("value-new"))
+			if err = db.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			events = nil
+			options.RecoveryMode = RecoveryStrict
+			if tt.olderFile {
+				options.RecoveryMode = tt.mode
+			}
+			if db, err = Open(options); err != nil {
+				t.Errorf("Open() after recovery error = %v", err)
+				return
+			}
+			if got, _ := db.Get([]byte("key-new")); string(got) != "value-new" {
+				t.Errorf("Get(key-new) = %s", got)
+			}
+		})
+	}
+}
+
+func TestDB_RecoveryMode_options(t *testing.T) {
+	options := defaultOptions()
+	options.RecoveryMode = 10
+	if _, err := Open(options); err == nil {
+		t.Errorf("Open() with unknown recovery mode should fail")
+	}
+}
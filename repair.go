@@ -0,0 +1,304 @@
+package bitcask_go
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xiecang/bitcask/data"
+	"github.com/xiecang/bitcask/fio"
+	"io"
+	"log"
+	"math"
+	"os"
+)
+
+// RepairReport 修复数据目录的结果
+type RepairReport struct {
+	RecoveredRecords int   // 写入新数据目录的记录数量
+	RecoveredBytes   int64 // 写入新数据目录的记录在原数据文件中的大小，单位 byte
+	LostRecords      int   // 可以读取但被丢弃的记录数量，例如未完成的事务、无法解密或者 value 所在的 blob 文件损坏
+	LostBytes        int64 // 损坏和被丢弃的数据大小，单位 byte
+	RecoveredKeys    int   // 新数据目录中所有命名空间的 key 数量
+	LostKeys         int   // 被丢弃的记录中不在新数据目录中的 key 数量，损坏区域中的 key 无法统计
+}
+
+func (r *RepairReport) String() string {
+	return fmt.Sprintf("recovered %d records (%d bytes, %d keys), lost %d records (%d bytes, %d keys)",
+		r.RecoveredRecords, r.RecoveredBytes, r.RecoveredKeys, r.LostRecords, r.LostBytes, r.LostKeys)
+}
+
+// repairRecord 从原数据文件中读取到的一条记录
+type repairRecord struct {
+	record *data.LogRecord
+	size   int64
+}
+
+// repairer 逐条读取原数据文件中的记录并写入新数据库
+type repairer struct {
+	src          *DB // 只用于解密、解压缩原数据文件中的记录以及读取 blob 文件
+	dst          *DB
+	report       *RepairReport
+	transactions map[uint64][]repairRecord  // 尚未读取到完成标记的事务数据
+	broken       map[uint64]bool            // 存在无法读取的记录的事务，读取到完成标记时整体丢弃
+	lostKeys     map[string]*data.LogRecord // 被丢弃的记录，由命名空间和 key 共同确定
+}
+
+// Repair 从损坏的数据目录 srcDir 中抢救所有可以读取的数据，写入到新的数据目录 dstDir 中
+// 逐个扫描数据文件，跳过损坏的区域并从下一条 crc 校验通过的记录继续读取，没有完成标记的事务整体丢弃
+// options 用于打开新的数据目录，读取原数据目录时使用其中的加密和压缩配置，dstDir 必须不存在或者为空
+func Repair(srcDir, dstDir string, options Options) (*RepairReport, error) {
+	if entries, err := os.ReadDir(dstDir); err == nil && len(entries) > 0 {
+		return nil, ErrDirNotEmpty
+	}
+	fileIds, err := dataFileIds(srcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	options.DirPath = dstDir
+	options.ReadOnly = false
+	dst, err := Open(options)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = dst.Close() }()
+
+	srcOptions := options
+	srcOptions.DirPath = srcDir
+	src := &DB{options: srcOptions, blobFiles: make(map[uint32]*data.File)}
+	src.compressors, _ = newCompressors(srcOptions)
+	src.encryptor = newEncryptor(srcOptions)
+	blobIds, err := blobFileIds(srcDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fid := range blobIds {
+		file, err := data.OpenBlobFile(srcDir, uint32(fid), fio.FIOStandar)
+		if err != nil {
+			return nil, err
+		}
+		src.blobFiles[uint32(fid)] = file
+	}
+	defer func() {
+		for _, file := range src.blobFiles {
+			_ = file.Close()
+		}
+	}()
+
+	r := &repairer{
+		src:          src,
+		dst:          dst,
+		report:       &RepairReport{},
+		transactions: make(map[uint64][]repairRecord),
+		broken:       make(map[uint64]bool),
+		lostKeys:     make(map[string]*data.LogRecord),
+	}
+	for _, fid := range fileIds {
+		if err = r.repairDataFile(uint32(fid)); err != nil {
+			return nil, err
+		}
+	}
+	// 没有完成标记的事务整体丢弃
+	for seqId := range r.transactions {
+		r.dropTransaction(seqId)
+	}
+	if err = dst.Sync(); err != nil {
+		return nil, err
+	}
+
+	report := r.report
+	dst.mu.RLock()
+	report.RecoveredKeys = dst.index.Size()
+	for _, ns := range dst.namespaces {
+		report.RecoveredKeys += ns.index.Size()
+	}
+	for _, record := range r.lostKeys {
+		var indexer = dst.index
+		if len(record.Namespace) > 0 {
+			ns, ok := dst.namespaces[string(record.Namespace)]
+			if !ok {
+				report.LostKeys++
+				continue
+			}
+			indexer = ns.index
+		}
+		if indexer.Get(record.Key) == nil {
+			report.LostKeys++
+		}
+	}
+	dst.mu.RUnlock()
+	if dst.options.Logger == nil {
+		// 默认的日志不输出 Info 级别的事件，修复结果需要让使用者看到
+		log.Printf("bitcask: repair finished src=%s dst=%s: %s", srcDir, dstDir, report.String())
+		return report, nil
+	}
+	dst.logger().Info("repair finished",
+		"src", srcDir,
+		"dst", dstDir,
+		"recovered_records", report.RecoveredRecords,
+		"recovered_bytes", report.RecoveredBytes,
+		"recovered_keys", report.RecoveredKeys,
+		"lost_records", report.LostRecords,
+		"lost_bytes", report.LostBytes,
+		"lost_keys", report.LostKeys)
+	return report, nil
+}
+
+// repairDataFile 读取数据文件中所有可以读取的记录，损坏的区域计入丢失的数据
+func (r *repairer) repairDataFile(fileId uint32) error {
+	file, err := data.OpenFile(r.src.options.DirPath, fileId, fio.FIOStandar)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	fileSize, err := file.IOManager.Size()
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	for offset < fileSize {
+		record, size, err := file.ReadLogRecord(offset)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			next := resyncDataFile(file, offset, fileSize)
+			r.report.LostBytes += next - offset
+			offset = next
+			continue
+		}
+		offset += size
+		if err = r.repair(record, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repair 将一条记录写入新数据库，事务记录在读取到完成标记之后一起写入
+func (r *repairer) repair(record *data.LogRecord, size int64) error {
+	if err := r.src.decryptRecord(record); err != nil {
+		// 无法解密时 key 和所属的事务都无法确定
+		r.report.LostRecords++
+		r.report.LostBytes += size
+		return nil
+	}
+	realKey, seqId := parsedLogRecordKey(record.Key)
+	if record.Type == data.LogRecordTypeTransactionFinished {
+		return r.commitTransaction(seqId, size)
+	}
+	record.Key = realKey
+
+	var err error
+	if record.Type == data.LogRecordTypeNormal {
+		err = r.resolveValue(record)
+	}
+	if err != nil {
+		if seqId == nonTransactionSeqId {
+			r.lose(record, size)
+		} else {
+			r.broken[seqId] = true
+			r.transactions[seqId] = append(r.transactions[seqId], repairRecord{record: record, size: size})
+		}
+		return nil
+	}
+	if seqId != nonTransactionSeqId {
+		r.transactions[seqId] = append(r.transactions[seqId], repairRecord{record: record, size: size})
+		return nil
+	}
+
+	ns, err := r.namespace(record.Namespace)
+	if err != nil {
+		r.lose(record, size)
+		return nil
+	}
+	switch record.Type {
+	case data.LogRecordTypeNormal:
+		err = r.dst.put(ns, realKey, record.Value, record.Expire)
+	case data.LogRecordTypeDelete:
+		err = r.dst.delete(ns, realKey)
+	case data.LogRecordTypeNamespaceDrop:
+		if ns != nil {
+			err = r.dst.DropNamespace(ns.Name())
+		}
+	case data.LogRecordTypeRangeDelete:
+		err = r.dst.deleteRange(ns, realKey, record.Value)
+	}
+	if err != nil {
+		return err
+	}
+	r.recover(size)
+	return nil
+}
+
+// resolveValue 读取原始的 value，value 存储在 blob 文件中时从原数据目录的 blob 文件中读取
+func (r *repairer) resolveValue(record *data.LogRecord) error {
+	if record.ValueInBlob {
+		value, err := r.src.readBlob(data.DecodeLogRecordPos(record.Value))
+		if err != nil {
+			return err
+		}
+		record.Value, record.ValueInBlob = value, false
+		return nil
+	}
+	return r.src.decompressRecord(record)
+}
+
+// commitTransaction 读取到事务完成标记之后，将事务中的所有记录作为一个批次写入新数据库
+func (r *repairer) commitTransaction(seqId uint64, size int64) error {
+	records, broken := r.transactions[seqId], r.broken[seqId]
+	if broken {
+		r.dropTransaction(seqId)
+		r.report.LostRecords++
+		r.report.LostBytes += size
+		return nil
+	}
+	delete(r.transactions, seqId)
+
+	wb := r.dst.NewWriteBatch(DefaultWriteBatchOptions)
+	wb.options.MaxBatchSize = math.MaxUint
+	for _, rr := range records {
+		if _, err := r.namespace(rr.record.Namespace); err != nil {
+			return err
+		}
+		wb.putRecord(rr.record)
+	}
+	if err := wb.Commit(); err != nil {
+		return err
+	}
+	for _, rr := range records {
+		r.recover(rr.size)
+	}
+	r.recover(size)
+	return nil
+}
+
+// dropTransaction 丢弃事务中的所有记录
+func (r *repairer) dropTransaction(seqId uint64) {
+	for _, rr := range r.transactions[seqId] {
+		r.lose(rr.record, rr.size)
+	}
+	delete(r.transactions, seqId)
+	delete(r.broken, seqId)
+}
+
+// namespace 获取新数据库中的命名空间，name 为空时返回 nil 表示默认命名空间
+func (r *repairer) namespace(name []byte) (*Namespace, error) {
+	if len(name) == 0 {
+		return nil, nil
+	}
+	return r.dst.Namespace(string(name))
+}
+
+func (r *repairer) recover(size int64) {
+	r.report.RecoveredRecords++
+	r.report.RecoveredBytes += size
+}
+
+func (r *repairer) lose(record *data.LogRecord, size int64) {
+	r.report.LostRecords++
+	r.report.LostBytes += size
+	if record.Type == data.LogRecordTypeNormal || record.Type == data.LogRecordTypeDelete {
+		r.lostKeys[pendingWriteKey(record.Namespace, record.Key)] = record
+	}
+}
@@ -0,0 +1,165 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/xiecang/bitcask/data"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt bool // 损坏第一个数据文件中 key-01 的记录
+		options func(options *Options)
+	}{
+		{name: "clean"},
+		{name: "corrupt record", corrupt: true},
+		{name: "compressed and encrypted", corrupt: true, options: func(options *Options) {
+			options.Compression = CompressionGzip
+			options.EncryptionKey = bytes.Repeat([]byte{1}, 32)
+		}},
+		{name: "large values", corrupt: true, options: func(options *Options) {
+			options.LargeValueThreshold = 64
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.MaxFileSize = 512
+			if tt.options != nil {
+				tt.options(&options)
+			}
+			var srcDir, dstDir = options.DirPath, options.DirPath + "-repair"
+			defer func() {
+				_ = os.RemoveAll(srcDir)
+				_ = os.RemoveAll(dstDir)
+			}()
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			var large = bytes.Repeat([]byte("v"), 100)
+			for i := 0; i < 30; i++ {
+				_ = db.Put([]byte(fmt.Sprintf("key-%02d", i)), []byte(fmt.Sprintf("value-%02d", i)))
+			}
+			_ = db.Put([]byte("large"), large)
+			_ = db.Delete([]byte("key-29"))
+			ns, _ := db.Namespace("ns")
+			_ = ns.Put([]byte("key-00"), []byte("ns-value"))
+			wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+			_ = wb.Put([]byte("batch-a"), []byte("a"))
+			_ = wb.Put([]byte("batch-b"), []byte("b"))
+			if err = wb.Commit(); err != nil {
+				t.Errorf("Commit() error = %v", err)
+			}
+			// 没有完成标记的事务整体丢弃
+			db.mu.Lock()
+			for _, key := range []string{"torn-a", "torn-b"} {
+				_, _ = db.appendLogRecord(&data.LogRecord{
+					Key:   logRecordKeyWithSeq([]byte(key), db.seqId+1),
+					Value: []byte("torn"),
+				})
+			}
+			db.mu.Unlock()
+			pos := db.index.Get([]byte("key-01"))
+			if err = db.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			if tt.corrupt {
+				flipByte(t, data.GetFilePath(options.DirPath, pos.Fid), pos.Offset+int64(pos.Size)-1)
+			}
+
+			report, err := Repair(options.DirPath, dstDir, options)
+			if err != nil {
+				t.Errorf("Repair() error = %v", err)
+				return
+			}
+			var wantKeys = 33
+			if tt.corrupt {
+				wantKeys--
+			}
+			if report.RecoveredKeys != wantKeys || report.LostRecords != 2 || report.LostKeys != 2 {
+				t.Errorf("Repair() report = %+v", report)
+			}
+			if tt.corrupt && report.LostBytes <= 2*int64(pos.Size) {
+				t.Errorf("Repair() lost bytes = %d", report.LostBytes)
+			}
+
+			options.DirPath = dstDir
+			if db, err = Open(options); err != nil {
+				t.Errorf("Open() repaired error = %v", err)
+				return
+			}
+			defer func() { _ = db.Close() }()
+			var want = map[string]string{
+				"key-00":  "value-00",
+				"key-02":  "value-02",
+				"key-28":  "value-28",
+				"large":   string(large),
+				"batch-a": "a",
+				"batch-b": "b",
+			}
+			for key, value := range want {
+				if got, err := db.Get([]byte(key)); string(got) != value {
+					t.Errorf("Get(%s) = %s, error = %v", key, got, err)
+				}
+			}
+			for _, key := range []string{"key-29", "torn-a", "torn-b"} {
+				if _, err := db.Get([]byte(key)); err != ErrKeyNotFound {
+					t.Errorf("Get(%s) error = %v, want %v", key, err, ErrKeyNotFound)
+				}
+			}
+			if _, err := db.Get([]byte("key-01")); tt.corrupt != (err == ErrKeyNotFound) {
+				t.Errorf("Get(key-01) error = %v", err)
+			}
+			ns, _ = db.Namespace("ns")
+			if got, _ := ns.Get([]byte("key-00")); string(got) != "ns-value" {
+				t.Errorf("Namespace Get(key-00) = %s", got)
+			}
+		})
+	}
+}
+
+func TestRepair_dstNotEmpty(t *testing.T) {
+	options := defaultOptions()
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+	if _, err = Repair(t.TempDir(), options.DirPath, options); err != ErrDirNotEmpty {
+		t.Errorf("Repair() error = %v, want %v", err, ErrDirNotEmpty)
+	}
+}
+
+func TestRepair_defaultLogger(t *testing.T) {
+	options := defaultOptions()
+	var dstDir = options.DirPath + "-repair"
+	defer func() {
+		_ = os.RemoveAll(options.DirPath)
+		_ = os.RemoveAll(dstDir)
+	}()
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	_ = db.Put([]byte("key"), []byte("value"))
+	_ = db.Close()
+
+	// 没有设置 Options.Logger 时修复结果输出到标准库 log
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	report, err := Repair(options.DirPath, dstDir, options)
+	if err != nil {
+		t.Fatalf("Repair() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), report.String()) {
+		t.Errorf("log output = %q, want to contain %q", buf.String(), report.String())
+	}
+}
@@ -0,0 +1,126 @@
+package bitcask_go
+
+import (
+	"github.com/xiecang/bitcask/index"
+	"sync"
+	"time"
+)
+
+// Snapshot 数据库在某一时刻的只读快照
+// 快照持有创建时刻内存索引的副本，之后的 Put/Delete/WriteBatch.Commit 对快照不可见。
+// 数据文件只会追加写入，merge 生成的新文件也只会在下次 Open 时替换旧文件，
+// 因此快照引用的数据位置在数据库关闭之前始终有效
+type Snapshot struct {
+	db       *DB
+	mu       *sync.RWMutex
+	index    index.Indexer // 快照时刻的内存索引副本
+	ts       int64         // 快照创建时刻，用于判断数据是否过期
+	released bool          // 快照是否已经释放
+}
+
+// Snapshot 创建数据库当前时刻的快照，使用完毕后需要调用 Release 释放
+func (db *DB) Snapshot() *Snapshot {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	s := &Snapshot{
+		db:    db,
+		mu:    &sync.RWMutex{},
+		index: cloneIndex(db.index),
+		ts:    time.Now().UnixNano(),
+	}
+	db.snapshots[s] = struct{}{}
+	return s
+}
+
+// cloneIndex 拷贝内存索引，BTree 索引使用写时复制，其他类型的索引逐个拷贝到 BTree 中
+func cloneIndex(idx index.Indexer) index.Indexer {
+	if bt, ok := idx.(*index.BTree); ok {
+		return bt.Clone()
+	}
+	var clone = index.NewBTree()
+	iterator := idx.Iterator(false)
+	defer iterator.Close()
+	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		clone.Put(iterator.Key(), iterator.Value())
+	}
+	return clone
+}
+
+// Get 读取快照中 key 对应的数据
+func (s *Snapshot) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.released {
+		return nil, ErrSnapshotReleased
+	}
+
+	pos := s.index.Get(key)
+	if pos == nil || pos.IsExpired(s.ts) {
+		return nil, ErrKeyNotFound
+	}
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	return s.db.getValueByPosition(pos)
+}
+
+// NewIterator 创建快照的迭代器，快照释放之后返回的迭代器为空
+func (s *Snapshot) NewIterator(opt *IteratorOption) *Iterator {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var idx = s.index
+	if s.released {
+		idx = index.NewBTree()
+	}
+	it := s.db.newIterator(idx.Iterator(opt.Reverse), opt)
+	it.readTs = s.ts
+	return it
+}
+
+// Fold 遍历快照中的所有 key-value, fn 返回 false 时停止遍历
+// 每次读取数据时才持有数据库的读锁，遍历过程中不会阻塞写入
+func (s *Snapshot) Fold(fn func(key, value []byte) bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.released {
+		return ErrSnapshotReleased
+	}
+
+	iterator := s.index.Iterator(false)
+	defer iterator.Close()
+
+	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		pos := iterator.Value()
+		if pos.IsExpired(s.ts) {
+			continue
+		}
+		s.db.mu.RLock()
+		value, err := s.db.getValueByPosition(pos)
+		s.db.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+		if !fn(iterator.Key(), value) {
+			break
+		}
+	}
+	return nil
+}
+
+// Release 释放快照
+func (s *Snapshot) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.released {
+		return
+	}
+	s.released = true
+	s.index = nil
+
+	s.db.mu.Lock()
+	delete(s.db.snapshots, s)
+	s.db.mu.Unlock()
+}
@@ -0,0 +1,105 @@
+package bitcask_go
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDB_Snapshot(t *testing.T) {
+	for _, indexType := range indexTypesForTest {
+		options := defaultOptions()
+		options.IndexType = indexType
+		t.Run(indexTypeString(indexType), func(t *testing.T) {
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer destroyDB(db)
+			_ = db.Put([]byte("key1"), []byte("value1"))
+			_ = db.Put([]byte("key2"), []byte("value2"))
+
+			snapshot := db.Snapshot()
+			defer snapshot.Release()
+
+			// 快照创建之后的写入对快照不可见
+			_ = db.Put([]byte("key1"), []byte("value1-new"))
+			_ = db.Delete([]byte("key2"))
+			_ = db.Put([]byte("key3"), []byte("value3"))
+			wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+			_ = wb.Put([]byte("key4"), []byte("value4"))
+			if err = wb.Commit(); err != nil {
+				t.Errorf("Commit() error = %v", err)
+			}
+
+			if value, err := snapshot.Get([]byte("key1")); err != nil || string(value) != "value1" {
+				t.Errorf("Snapshot.Get() = %s, error = %v, want value1", value, err)
+			}
+			if value, err := snapshot.Get([]byte("key2")); err != nil || string(value) != "value2" {
+				t.Errorf("Snapshot.Get() = %s, error = %v, want value2", value, err)
+			}
+			if _, err := snapshot.Get([]byte("key3")); !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("Snapshot.Get() error = %v, wantErr %v", err, ErrKeyNotFound)
+			}
+
+			var got []string
+			iterator := snapshot.NewIterator(defaultIteratorOption())
+			for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+				value, err := iterator.Value()
+				if err != nil {
+					t.Errorf("Iterator.Value() error = %v", err)
+				}
+				got = append(got, fmt.Sprintf("%s=%s", iterator.Key(), value))
+			}
+			iterator.Close()
+			var want = []string{"key1=value1", "key2=value2"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Snapshot.NewIterator() = %v, want %v", got, want)
+			}
+
+			got = nil
+			if err = snapshot.Fold(func(key, value []byte) bool {
+				got = append(got, fmt.Sprintf("%s=%s", key, value))
+				return true
+			}); err != nil {
+				t.Errorf("Snapshot.Fold() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Snapshot.Fold() = %v, want %v", got, want)
+			}
+
+			// 数据库本身可以读到最新的数据
+			if value, err := db.Get([]byte("key1")); err != nil || string(value) != "value1-new" {
+				t.Errorf("Get() = %s, error = %v, want value1-new", value, err)
+			}
+		})
+	}
+}
+
+func TestSnapshot_Release(t *testing.T) {
+	db, err := Open(defaultOptions())
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer destroyDB(db)
+	_ = db.Put([]byte("key"), []byte("value"))
+
+	snapshot := db.Snapshot()
+	if len(db.snapshots) != 1 {
+		t.Errorf("Snapshot() live snapshots = %v, want 1", len(db.snapshots))
+	}
+	snapshot.Release()
+	snapshot.Release()
+	if len(db.snapshots) != 0 {
+		t.Errorf("Release() live snapshots = %v, want 0", len(db.snapshots))
+	}
+	if _, err = snapshot.Get([]byte("key")); !errors.Is(err, ErrSnapshotReleased) {
+		t.Errorf("Snapshot.Get() error = %v, wantErr %v", err, ErrSnapshotReleased)
+	}
+	if err = snapshot.Fold(func(key, value []byte) bool { return true }); !errors.Is(err, ErrSnapshotReleased) {
+		t.Errorf("Snapshot.Fold() error = %v, wantErr %v", err, ErrSnapshotReleased)
+	}
+}
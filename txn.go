@@ -0,0 +1,310 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"github.com/xiecang/bitcask/data"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Txn 乐观读写事务
+// 写入暂存在 WriteBatch 中，读取时优先读取事务自身尚未提交的写入；
+// 提交时如果事务读取过的 key 在 Begin 之后被其他提交修改过，返回 ErrTxnConflict
+type Txn struct {
+	db       *DB
+	mu       *sync.Mutex
+	batch    *WriteBatch         // 暂存待写入的数据
+	startSeq uint64              // 事务开始时的序列号
+	reads    map[string]struct{} // 事务读取过的 key
+	finished bool                // 事务是否已经提交或回滚
+}
+
+// committedWrite 一次已提交写入所修改的 key
+type committedWrite struct {
+	seqId uint64
+	keys  map[string]struct{}
+}
+
+// Begin 开启一个乐观读写事务
+func (db *DB) Begin() *Txn {
+	batch := db.NewWriteBatch(DefaultWriteBatchOptions)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	txn := &Txn{
+		db:       db,
+		mu:       &sync.Mutex{},
+		batch:    batch,
+		startSeq: atomic.LoadUint64(&db.seqId),
+		reads:    make(map[string]struct{}),
+	}
+	db.txns[txn] = struct{}{}
+	return txn
+}
+
+// trackWrites 在有进行中的事务时记录本次提交修改的 key，需要持有 db.mu
+func (db *DB) trackWrites(seqId uint64, keys ...[]byte) {
+	if len(db.txns) == 0 {
+		return
+	}
+	write := &committedWrite{
+		seqId: seqId,
+		keys:  make(map[string]struct{}, len(keys)),
+	}
+	for _, key := range keys {
+		write.keys[string(key)] = struct{}{}
+	}
+	db.committedWrites = append(db.committedWrites, write)
+}
+
+// finishTxn 结束事务，并清理不再需要的已提交写入记录，需要持有 db.mu
+func (db *DB) finishTxn(txn *Txn) {
+	delete(db.txns, txn)
+	if len(db.txns) == 0 {
+		db.committedWrites = nil
+		return
+	}
+
+	var minSeq = ^uint64(0)
+	for t := range db.txns {
+		if t.startSeq < minSeq {
+			minSeq = t.startSeq
+		}
+	}
+	var i int
+	for i < len(db.committedWrites) && db.committedWrites[i].seqId <= minSeq {
+		i++
+	}
+	db.committedWrites = db.committedWrites[i:]
+}
+
+// hasConflict 判断事务读取过的 key 在事务开始之后是否被修改过，需要持有 db.mu
+func (txn *Txn) hasConflict() bool {
+	for _, write := range txn.db.committedWrites {
+		if write.seqId <= txn.startSeq {
+			continue
+		}
+		for key := range txn.reads {
+			if _, ok := write.keys[key]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Get 读取数据，优先读取事务中尚未提交的写入
+func (txn *Txn) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return nil, ErrTxnFinished
+	}
+
+	if record, ok := txn.pendingRecord(key); ok {
+		if record.Type == data.LogRecordTypeDelete {
+			return nil, ErrKeyNotFound
+		}
+		return record.Value, nil
+	}
+	txn.reads[string(key)] = struct{}{}
+	return txn.db.Get(key)
+}
+
+// Put 在事务中写入数据
+func (txn *Txn) Put(key, value []byte) error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return ErrTxnFinished
+	}
+	return txn.batch.Put(key, value)
+}
+
+// Delete 在事务中删除数据
+func (txn *Txn) Delete(key []byte) error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return ErrTxnFinished
+	}
+	return txn.batch.Delete(key)
+}
+
+// Commit 提交事务，如果读取过的数据在事务开始之后被修改过，返回 ErrTxnConflict
+// 无论提交是否成功，事务都会结束
+func (txn *Txn) Commit() error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return ErrTxnFinished
+	}
+	txn.finished = true
+
+	w := txn.batch
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	db := txn.db
+	db.lockForWrite()
+	defer db.mu.Unlock()
+	defer db.finishTxn(txn)
+
+	if uint(len(w.pendingWrites)) > w.options.MaxBatchSize {
+		return ErrExceedMaxBatchSize
+	}
+	if txn.hasConflict() {
+		return ErrTxnConflict
+	}
+	if len(w.pendingWrites) == 0 {
+		return nil
+	}
+	return w.commit()
+}
+
+// Rollback 回滚事务，丢弃所有尚未提交的写入
+func (txn *Txn) Rollback() {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return
+	}
+	txn.finished = true
+
+	txn.db.mu.Lock()
+	defer txn.db.mu.Unlock()
+	txn.db.finishTxn(txn)
+}
+
+// pendingRecord 查找事务中 key 对应的暂存数据
+func (txn *Txn) pendingRecord(key []byte) (*data.LogRecord, bool) {
+	txn.batch.mu.Lock()
+	defer txn.batch.mu.Unlock()
+	record, ok := txn.batch.pendingWrites[pendingWriteKey(nil, key)]
+	return record, ok
+}
+
+// txnIteratorItem 事务迭代器中的元素，value 不为空时表示事务中尚未提交的数据
+type txnIteratorItem struct {
+	key   []byte
+	pos   *data.LogRecordPos
+	value []byte
+}
+
+// TxnIterator 事务迭代器，合并了数据库中的数据和事务中尚未提交的写入
+type TxnIterator struct {
+	txn       *Txn
+	option    *IteratorOption
+	items     []*txnIteratorItem
+	currIndex int
+	closed    bool
+}
+
+// NewIterator 创建事务迭代器，迭代器创建之后事务的写入对迭代器不可见
+func (txn *Txn) NewIterator(opt *IteratorOption) *TxnIterator {
+	var items = make(map[string]*txnIteratorItem)
+	var now = time.Now().UnixNano()
+
+	indexIter := txn.db.index.Iterator(false)
+	for indexIter.Rewind(); indexIter.Valid(); indexIter.Next() {
+		key := indexIter.Key()
+		pos := indexIter.Value()
+		if !bytes.HasPrefix(key, opt.Prefix) || pos.IsExpired(now) {
+			continue
+		}
+		items[string(key)] = &txnIteratorItem{key: key, pos: pos}
+	}
+	indexIter.Close()
+
+	txn.batch.mu.Lock()
+	for _, record := range txn.batch.pendingWrites {
+		if !bytes.HasPrefix(record.Key, opt.Prefix) {
+			continue
+		}
+		if record.Type == data.LogRecordTypeDelete {
+			delete(items, string(record.Key))
+		} else {
+			items[string(record.Key)] = &txnIteratorItem{key: record.Key, value: record.Value}
+		}
+	}
+	txn.batch.mu.Unlock()
+
+	var sorted = make([]*txnIteratorItem, 0, len(items))
+	for _, item := range items {
+		sorted = append(sorted, item)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if opt.Reverse {
+			return bytes.Compare(sorted[i].key, sorted[j].key) > 0
+		}
+		return bytes.Compare(sorted[i].key, sorted[j].key) < 0
+	})
+	atomic.AddInt32(&txn.db.iterators, 1)
+	return &TxnIterator{
+		txn:    txn,
+		option: opt,
+		items:  sorted,
+	}
+}
+
+func (it *TxnIterator) Rewind() {
+	it.currIndex = 0
+}
+
+func (it *TxnIterator) Seek(key []byte) {
+	it.currIndex = sort.Search(len(it.items), func(i int) bool {
+		if it.option.Reverse {
+			return bytes.Compare(it.items[i].key, key) <= 0
+		}
+		return bytes.Compare(it.items[i].key, key) >= 0
+	})
+}
+
+func (it *TxnIterator) Next() {
+	it.currIndex++
+}
+
+func (it *TxnIterator) Valid() bool {
+	return it.currIndex < len(it.items)
+}
+
+// Key 返回当前位置的 key，读取过的 key 会参与提交时的冲突检测
+func (it *TxnIterator) Key() []byte {
+	item := it.items[it.currIndex]
+	it.trackRead(item)
+	return item.key
+}
+
+func (it *TxnIterator) Value() ([]byte, error) {
+	item := it.items[it.currIndex]
+	it.trackRead(item)
+	if item.pos == nil {
+		return item.value, nil
+	}
+	it.txn.db.mu.RLock()
+	defer it.txn.db.mu.RUnlock()
+	return it.txn.db.getValueByPosition(item.pos)
+}
+
+func (it *TxnIterator) Close() {
+	if it.closed {
+		return
+	}
+	it.closed = true
+	it.items = nil
+	atomic.AddInt32(&it.txn.db.iterators, -1)
+}
+
+func (it *TxnIterator) trackRead(item *txnIteratorItem) {
+	if item.pos == nil {
+		return
+	}
+	it.txn.mu.Lock()
+	it.txn.reads[string(item.key)] = struct{}{}
+	it.txn.mu.Unlock()
+}
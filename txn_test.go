@@ -0,0 +1,236 @@
+package bitcask_go
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTxn_Get(t *testing.T) {
+	db, err := Open(defaultOptions())
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer destroyDB(db)
+	_ = db.Put([]byte("key1"), []byte("value1"))
+	_ = db.Put([]byte("key2"), []byte("value2"))
+
+	txn := db.Begin()
+	defer txn.Rollback()
+	_ = txn.Put([]byte("key1"), []byte("value1-txn"))
+	_ = txn.Delete([]byte("key2"))
+	_ = txn.Put([]byte("key3"), []byte("value3-txn"))
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr error
+	}{
+		{key: "key1", want: "value1-txn"},
+		{key: "key2", wantErr: ErrKeyNotFound},
+		{key: "key3", want: "value3-txn"},
+		{key: "key4", wantErr: ErrKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := txn.Get([]byte(tt.key))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Txn.Get() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("Txn.Get() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// 未提交的数据对数据库不可见
+	if value, err := db.Get([]byte("key1")); err != nil || string(value) != "value1" {
+		t.Errorf("Get() = %s, error = %v, want value1", value, err)
+	}
+}
+
+func TestTxn_Commit(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   func(db *DB) error // 事务读取之后，由其他写入方执行的写入
+		wantErr error
+	}{
+		{
+			name:  "no conflict",
+			write: func(db *DB) error { return nil },
+		},
+		{
+			name:  "unrelated key written",
+			write: func(db *DB) error { return db.Put([]byte("other"), []byte("value")) },
+		},
+		{
+			name:    "put conflict",
+			write:   func(db *DB) error { return db.Put([]byte("counter"), []byte("10")) },
+			wantErr: ErrTxnConflict,
+		},
+		{
+			name:    "delete conflict",
+			write:   func(db *DB) error { return db.Delete([]byte("counter")) },
+			wantErr: ErrTxnConflict,
+		},
+		{
+			name: "write batch conflict",
+			write: func(db *DB) error {
+				wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+				_ = wb.Put([]byte("counter"), []byte("10"))
+				return wb.Commit()
+			},
+			wantErr: ErrTxnConflict,
+		},
+		{
+			name: "other txn conflict",
+			write: func(db *DB) error {
+				other := db.Begin()
+				_ = other.Put([]byte("counter"), []byte("10"))
+				return other.Commit()
+			},
+			wantErr: ErrTxnConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Open(defaultOptions())
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer destroyDB(db)
+			_ = db.Put([]byte("counter"), []byte("1"))
+
+			txn := db.Begin()
+			if _, err = txn.Get([]byte("counter")); err != nil {
+				t.Errorf("Txn.Get() error = %v", err)
+			}
+			_ = txn.Put([]byte("counter"), []byte("2"))
+			if err = tt.write(db); err != nil {
+				t.Errorf("write error = %v", err)
+			}
+
+			if err = txn.Commit(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Txn.Commit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err = txn.Commit(); !errors.Is(err, ErrTxnFinished) {
+				t.Errorf("Txn.Commit() twice error = %v, wantErr %v", err, ErrTxnFinished)
+			}
+			if tt.wantErr == nil {
+				if value, err := db.Get([]byte("counter")); err != nil || string(value) != "2" {
+					t.Errorf("Get() = %s, error = %v, want 2", value, err)
+				}
+			}
+			if len(db.txns) != 0 || len(db.committedWrites) != 0 {
+				t.Errorf("Txn.Commit() txns = %v, committedWrites = %v", len(db.txns), len(db.committedWrites))
+			}
+		})
+	}
+}
+
+func TestTxn_Rollback(t *testing.T) {
+	db, err := Open(defaultOptions())
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer destroyDB(db)
+
+	txn := db.Begin()
+	_ = txn.Put([]byte("key"), []byte("value"))
+	txn.Rollback()
+	if _, err = db.Get([]byte("key")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+	if err = txn.Put([]byte("key"), []byte("value")); !errors.Is(err, ErrTxnFinished) {
+		t.Errorf("Txn.Put() error = %v, wantErr %v", err, ErrTxnFinished)
+	}
+	if len(db.txns) != 0 {
+		t.Errorf("Txn.Rollback() txns = %v, want 0", len(db.txns))
+	}
+}
+
+func TestTxn_Commit_exceedMaxBatchSize(t *testing.T) {
+	db, err := Open(defaultOptions())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	txn := db.Begin()
+	txn.batch.options.MaxBatchSize = 1
+	_ = txn.Put([]byte("key"), []byte("value"))
+	_ = txn.Put([]byte("key2"), []byte("value"))
+	if err = txn.Commit(); !errors.Is(err, ErrExceedMaxBatchSize) {
+		t.Errorf("Txn.Commit() error = %v, wantErr %v", err, ErrExceedMaxBatchSize)
+	}
+	// 提交失败的事务同样结束，之后的写入不再被记录
+	if len(db.txns) != 0 {
+		t.Errorf("Txn.Commit() txns = %v, want 0", len(db.txns))
+	}
+	_ = db.Put([]byte("key"), []byte("value"))
+	if len(db.committedWrites) != 0 {
+		t.Errorf("committedWrites = %v, want 0", len(db.committedWrites))
+	}
+}
+
+func TestTxn_NewIterator(t *testing.T) {
+	db, err := Open(defaultOptions())
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer destroyDB(db)
+	_ = db.Put([]byte("a1"), []byte("1"))
+	_ = db.Put([]byte("a2"), []byte("2"))
+	_ = db.Put([]byte("b1"), []byte("3"))
+
+	txn := db.Begin()
+	_ = txn.Put([]byte("a3"), []byte("4"))
+	_ = txn.Put([]byte("a1"), []byte("5"))
+	_ = txn.Delete([]byte("a2"))
+
+	tests := []struct {
+		name string
+		opt  *IteratorOption
+		want []string
+	}{
+		{
+			name: "all",
+			opt:  defaultIteratorOption(),
+			want: []string{"a1=5", "a3=4", "b1=3"},
+		},
+		{
+			name: "prefix reverse",
+			opt:  &IteratorOption{Prefix: []byte("a"), Reverse: true},
+			want: []string{"a3=4", "a1=5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			it := txn.NewIterator(tt.opt)
+			defer it.Close()
+			for it.Rewind(); it.Valid(); it.Next() {
+				value, err := it.Value()
+				if err != nil {
+					t.Errorf("TxnIterator.Value() error = %v", err)
+				}
+				got = append(got, fmt.Sprintf("%s=%s", it.Key(), value))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Txn.NewIterator() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 迭代读取过 b1，b1 被修改后提交冲突
+	_ = db.Put([]byte("b1"), []byte("6"))
+	if err = txn.Commit(); !errors.Is(err, ErrTxnConflict) {
+		t.Errorf("Txn.Commit() error = %v, wantErr %v", err, ErrTxnConflict)
+	}
+}
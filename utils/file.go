@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"context"
+	"io"
 	"os"
 	gopath "path"
 	"path/filepath"
@@ -36,6 +38,11 @@ func AvailableDiskSpace() (size uint64, err error) {
 
 // CopyDir 拷贝目录
 func CopyDir(src, dest string, exclude []string) error {
+	return CopyDirContext(context.Background(), src, dest, exclude)
+}
+
+// CopyDirContext 与 CopyDir 相同，ctx 取消时停止拷贝并返回 ctx.Err()，已经拷贝的文件不会删除
+func CopyDirContext(ctx context.Context, src, dest string, exclude []string) error {
 	// 目标文件夹不存在则创建
 	if _, err := os.Stat(dest); os.IsNotExist(err) {
 		if err = os.MkdirAll(dest, os.ModePerm); err != nil {
@@ -56,6 +63,9 @@ func CopyDir(src, dest string, exclude []string) error {
 		return nil
 	}
 	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if strings.Compare(path, src) == 0 {
 			// 跳过源目录
 			return nil
@@ -76,13 +86,65 @@ func CopyDir(src, dest string, exclude []string) error {
 			return os.MkdirAll(filepath.Join(dest, filename), os.ModePerm)
 		}
 
-		data, err := os.ReadFile(filepath.Join(src, filename))
-		if err != nil {
-			return err
-		}
-
-		return os.WriteFile(filepath.Join(dest, filename), data, info.Mode())
+		return copyFileContext(ctx, filepath.Join(src, filename), filepath.Join(dest, filename), info.Mode())
 	})
 
 	return err
 }
+
+// copyFileContext 拷贝文件 src 到 dest，每次读取之前检查 ctx 是否已经取消
+func copyFileContext(ctx context.Context, src, dest string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+	_, err = io.Copy(out, &contextReader{ctx: ctx, r: in})
+	return err
+}
+
+// contextReader ctx 取消之后读取时返回 ctx.Err()
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// CopyFile 拷贝文件 src 的前 size 字节到 dest 并持久化，size 小于 0 时拷贝整个文件
+func CopyFile(src, dest string, size int64) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+
+	if size < 0 {
+		_, err = io.Copy(out, in)
+	} else {
+		_, err = io.CopyN(out, in, size)
+	}
+	if err != nil {
+		return err
+	}
+	return out.Sync()
+}
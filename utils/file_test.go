@@ -108,3 +108,35 @@ func TestAvailableDiskSpace(t *testing.T) {
 		})
 	}
 }
+
+func TestCopyFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int64
+		want    string
+		wantErr bool
+	}{
+		{name: "whole file", size: -1, want: "hello world"},
+		{name: "prefix", size: 5, want: "hello"},
+		{name: "beyond end", size: 20, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			createTestDir()
+			defer destroyTestDir()
+			writeData("src", []byte("hello world"))
+
+			dest := filepath.Join(testDir, "dest")
+			if err := CopyFile(filepath.Join(testDir, "src"), dest, tt.size); (err != nil) != tt.wantErr {
+				t.Errorf("CopyFile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got, _ := os.ReadFile(dest); string(got) != tt.want {
+				t.Errorf("CopyFile() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
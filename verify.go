@@ -0,0 +1,292 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xiecang/bitcask/data"
+	"github.com/xiecang/bitcask/fio"
+	"github.com/xiecang/bitcask/index"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// verifyIndexBatch 在线校验索引时每次持有读锁校验的索引数量，避免长时间阻塞写入
+const verifyIndexBatch = 256
+
+// CorruptionKind 损坏的类型
+type CorruptionKind string
+
+const (
+	CorruptionInvalidCRC    CorruptionKind = "invalid_crc"    // 记录的 crc 校验值不一致
+	CorruptionTruncated     CorruptionKind = "truncated"      // 记录不完整，超出了文件末尾
+	CorruptionUnreadable    CorruptionKind = "unreadable"     // 记录无法读取或者内容无法解析
+	CorruptionIndexMismatch CorruptionKind = "index_mismatch" // 索引指向的记录无法读取或者 key 不一致
+)
+
+// Corruption 一处损坏的数据
+type Corruption struct {
+	File   string         // 文件名
+	FileId uint32         // 数据文件、hint 文件或 blob 文件的 id，其他文件为 0
+	Offset int64          // 损坏的数据在文件中的起始位置
+	Size   int64          // 损坏的数据大小，单位 byte，无法确定时为 0
+	Kind   CorruptionKind // 损坏的类型
+	Detail string         // 错误信息
+}
+
+// VerifyReport 数据校验的结果
+type VerifyReport struct {
+	FilesChecked   int          // 校验的文件数量
+	RecordsChecked int          // 校验的记录数量
+	IndexChecked   int          // 校验的索引数量
+	Corruptions    []Corruption // 发现的所有损坏
+}
+
+// OK 是否没有发现任何损坏
+func (r *VerifyReport) OK() bool {
+	return len(r.Corruptions) == 0
+}
+
+// verifyFile 需要校验的文件
+type verifyFile struct {
+	name   string
+	fileId uint32
+	limit  int64 // 只校验该位置之前的数据，小于 0 时校验整个文件
+	check  func(record *data.LogRecord) error
+}
+
+// Verify 校验数据目录中所有文件的 crc 和记录格式，并检查每一条索引是否指向 key 一致的有效记录
+// 校验文件时不持有锁，校验索引时分批持有读锁，不会在整个校验期间阻塞写入
+// 只校验开始时活跃文件中已经写入的数据
+func (db *DB) Verify(ctx context.Context) (*VerifyReport, error) {
+	db.mu.RLock()
+	var activeFileId, activeOffset int64 = -1, 0
+	if db.activeFile != nil {
+		activeFileId, activeOffset = int64(db.activeFile.Id), db.activeFile.WriteOffset
+	}
+	db.mu.RUnlock()
+
+	files, err := db.verifyFiles(activeFileId, activeOffset)
+	if err != nil {
+		return nil, err
+	}
+	var report = &VerifyReport{}
+	for _, f := range files {
+		if err = verifyLogFile(ctx, db.options.DirPath, f, report); err != nil {
+			return report, err
+		}
+	}
+	if err = db.verifyIndex(ctx, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// VerifyDir 离线校验数据目录，以只读模式打开数据目录之后执行 Verify
+// 数据文件中损坏的记录会被跳过并出现在结果中，加密的数据目录需要使用 Open 打开之后调用 Verify
+func VerifyDir(dirPath string) (*VerifyReport, error) {
+	options := DefaultOptions
+	options.DirPath = dirPath
+	options.ReadOnly = true
+	options.MMapAtStartup = false
+	options.RecoveryMode = RecoverySkipCorrupt
+	options.RecoveryCallback = func(RecoveryEvent) {}
+	db, err := Open(options)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = db.Close() }()
+	return db.Verify(context.Background())
+}
+
+// verifyFiles 列出数据目录中所有需要校验的文件，活跃文件只校验 activeOffset 之前的数据
+func (db *DB) verifyFiles(activeFileId, activeOffset int64) ([]verifyFile, error) {
+	var dirPath = db.options.DirPath
+	var files []verifyFile
+	var add = func(name string, fileId uint32, limit int64, check func(*data.LogRecord) error) {
+		if _, err := os.Stat(filepath.Join(dirPath, name)); err == nil {
+			files = append(files, verifyFile{name: name, fileId: fileId, limit: limit, check: check})
+		}
+	}
+
+	dataIds, err := dataFileIds(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, fid := range dataIds {
+		var limit int64 = -1
+		if int64(fid) == activeFileId {
+			limit = activeOffset
+		}
+		add(filepath.Base(data.GetFilePath(dirPath, uint32(fid))), uint32(fid), limit, nil)
+		add(filepath.Base(data.GetDataHintFilePath(dirPath, uint32(fid))), uint32(fid), -1, nil)
+	}
+	blobIds, err := blobFileIds(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, fid := range blobIds {
+		add(filepath.Base(data.GetBlobFilePath(dirPath, uint32(fid))), uint32(fid), -1, nil)
+	}
+	add(data.FileNameHint, 0, -1, nil)
+	add(data.FileNameSeqId, 0, -1, func(record *data.LogRecord) error {
+		if err := db.decryptRecord(record); err != nil {
+			return err
+		}
+		_, err := strconv.ParseUint(string(record.Value), 10, 64)
+		return err
+	})
+	add(data.FileNameMergeFinished, 0, -1, func(record *data.LogRecord) error {
+		_, err := strconv.Atoi(string(record.Value))
+		return err
+	})
+	return files, nil
+}
+
+// verifyLogFile 依次读取文件中的记录，遇到损坏的记录时记录下来，并从下一条有效的记录继续校验
+func verifyLogFile(ctx context.Context, dirPath string, f verifyFile, report *VerifyReport) error {
+	// 使用独立的文件句柄读取，不受数据库关闭文件或者回收 blob 文件的影响
+	ioManager, err := fio.NewIOManager(filepath.Join(dirPath, f.name), fio.FIOStandar)
+	if err != nil {
+		return err
+	}
+	file := &data.File{Id: f.fileId, IOManager: ioManager}
+	defer func() { _ = file.Close() }()
+
+	fileSize, err := file.IOManager.Size()
+	if err != nil {
+		return err
+	}
+	var limit = fileSize
+	if f.limit >= 0 && f.limit < fileSize {
+		limit = f.limit
+	}
+	report.FilesChecked++
+
+	var offset int64
+	for offset < limit {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		record, size, err := file.ReadLogRecord(offset)
+		if err == nil && offset+size > limit {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil && f.check != nil {
+			if checkErr := f.check(record); checkErr != nil {
+				err = fmt.Errorf("%w: %v", errUnparsableRecord, checkErr)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			next := resyncDataFile(file, offset, limit)
+			report.Corruptions = append(report.Corruptions, Corruption{
+				File:   f.name,
+				FileId: f.fileId,
+				Offset: offset,
+				Size:   next - offset,
+				Kind:   corruptionKindOf(err),
+				Detail: err.Error(),
+			})
+			offset = next
+			continue
+		}
+		report.RecordsChecked++
+		offset += size
+	}
+	return nil
+}
+
+var errUnparsableRecord = errors.New("record content is unparsable")
+
+// corruptionKindOf 根据读取记录时的错误判断损坏的类型
+func corruptionKindOf(err error) CorruptionKind {
+	switch {
+	case errors.Is(err, data.ErrInvalidCRC):
+		return CorruptionInvalidCRC
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return CorruptionTruncated
+	default:
+		return CorruptionUnreadable
+	}
+}
+
+// verifyIndex 检查所有命名空间的每一条索引是否指向 key 一致的有效记录
+func (db *DB) verifyIndex(ctx context.Context, report *VerifyReport) error {
+	db.mu.RLock()
+	var namespaces = []*Namespace{nil}
+	for _, ns := range db.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	db.mu.RUnlock()
+
+	for _, ns := range namespaces {
+		db.mu.RLock()
+		iterator := db.indexOf(ns).Iterator(false)
+		db.mu.RUnlock()
+
+		err := db.verifyIndexEntries(ctx, ns, iterator, report)
+		iterator.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyIndexEntries 分批持有读锁校验迭代器中的索引，校验时索引已经被修改的 key 直接跳过
+func (db *DB) verifyIndexEntries(ctx context.Context, ns *Namespace, iterator index.Iterator, report *VerifyReport) error {
+	iterator.Rewind()
+	for iterator.Valid() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		db.mu.RLock()
+		for i := 0; i < verifyIndexBatch && iterator.Valid(); i++ {
+			key, pos := iterator.Key(), iterator.Value()
+			iterator.Next()
+			if current := db.indexOf(ns).Get(key); current == nil || *current != *pos {
+				continue
+			}
+			report.IndexChecked++
+			if err := db.verifyIndexEntry(ns, key, pos); err != nil {
+				report.Corruptions = append(report.Corruptions, Corruption{
+					File:   filepath.Base(data.GetFilePath(db.options.DirPath, pos.Fid)),
+					FileId: pos.Fid,
+					Offset: pos.Offset,
+					Size:   int64(pos.Size),
+					Kind:   CorruptionIndexMismatch,
+					Detail: fmt.Sprintf("key %q: %v", key, err),
+				})
+			}
+		}
+		db.mu.RUnlock()
+	}
+	return nil
+}
+
+// verifyIndexEntry 检查索引指向的记录是否可以读取，并且 key 和命名空间一致，需要持有 db.mu
+func (db *DB) verifyIndexEntry(ns *Namespace, key []byte, pos *data.LogRecordPos) error {
+	record, err := db.readLogRecord(pos)
+	if err != nil {
+		return err
+	}
+	realKey, _ := parsedLogRecordKey(record.Key)
+	if !bytes.Equal(realKey, key) || !bytes.Equal(record.Namespace, namespaceName(ns)) {
+		return fmt.Errorf("record key is %q in namespace %q", realKey, record.Namespace)
+	}
+	if record.Type != data.LogRecordTypeNormal {
+		return fmt.Errorf("record type is %d", record.Type)
+	}
+	if record.ValueInBlob {
+		if _, err = db.readBlob(data.DecodeLogRecordPos(record.Value)); err != nil {
+			return fmt.Errorf("read blob: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package bitcask_go
+
+import (
+	"context"
+	"fmt"
+	"github.com/xiecang/bitcask/data"
+	"os"
+	"sync"
+	"testing"
+)
+
+// flipByte 修改文件中 offset 位置的一个字节
+func flipByte(t *testing.T, path string, offset int64) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	var b = make([]byte, 1)
+	_, _ = f.ReadAt(b, offset)
+	_, _ = f.WriteAt([]byte{b[0] ^ 0xff}, offset)
+	_ = f.Close()
+}
+
+func TestVerifyDir(t *testing.T) {
+	tests := []struct {
+		name      string
+		corrupt   func(t *testing.T, dirPath string, pos *data.LogRecordPos)
+		wantKinds []CorruptionKind
+		wantFile  string
+	}{
+		{name: "clean"},
+		{
+			name: "data record",
+			corrupt: func(t *testing.T, dirPath string, pos *data.LogRecordPos) {
+				flipByte(t, data.GetFilePath(dirPath, pos.Fid), pos.Offset+int64(pos.Size)-1)
+			},
+			// hint 文件仍然索引了损坏的记录
+			wantKinds: []CorruptionKind{CorruptionInvalidCRC, CorruptionIndexMismatch},
+			wantFile:  "0000000000.data",
+		},
+		{
+			name: "truncated data file",
+			corrupt: func(t *testing.T, dirPath string, pos *data.LogRecordPos) {
+				path := data.GetFilePath(dirPath, pos.Fid)
+				info, _ := os.Stat(path)
+				_ = os.Truncate(path, info.Size()-3)
+			},
+			wantKinds: []CorruptionKind{CorruptionTruncated},
+			wantFile:  "0000000000.data",
+		},
+		{
+			name: "hint file",
+			corrupt: func(t *testing.T, dirPath string, pos *data.LogRecordPos) {
+				flipByte(t, data.GetDataHintFilePath(dirPath, pos.Fid), 0)
+			},
+			wantKinds: []CorruptionKind{CorruptionInvalidCRC},
+			wantFile:  "0000000000.hint",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := defaultOptions()
+			options.MaxFileSize = 256
+			db, err := Open(options)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer func() { _ = os.RemoveAll(options.DirPath) }()
+			for i := 0; i < 30; i++ {
+				_ = db.Put([]byte(fmt.Sprintf("key-%02d", i)), []byte(fmt.Sprintf("value-%02d", i)))
+			}
+			_ = db.Delete([]byte("key-00"))
+			pos := db.index.Get([]byte("key-01"))
+			if err = db.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			if tt.corrupt != nil {
+				tt.corrupt(t, options.DirPath, pos)
+			}
+
+			report, err := VerifyDir(options.DirPath)
+			if err != nil {
+				t.Errorf("VerifyDir() error = %v", err)
+				return
+			}
+			if report.FilesChecked == 0 || report.RecordsChecked == 0 || report.IndexChecked == 0 {
+				t.Errorf("VerifyDir() report = %+v", report)
+			}
+			if len(report.Corruptions) != len(tt.wantKinds) {
+				t.Errorf("VerifyDir() corruptions = %+v, want %v", report.Corruptions, tt.wantKinds)
+				return
+			}
+			for i, c := range report.Corruptions {
+				if c.Kind != tt.wantKinds[i] || c.File != tt.wantFile || c.Size == 0 {
+					t.Errorf("VerifyDir() corruption = %+v", c)
+				}
+			}
+			if report.OK() != (len(tt.wantKinds) == 0) {
+				t.Errorf("OK() = %v", report.OK())
+			}
+		})
+	}
+}
+
+func TestDB_Verify_indexMismatch(t *testing.T) {
+	options := defaultOptions()
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+	_ = db.Put([]byte("key-a"), []byte("value-a"))
+	_ = db.Put([]byte("key-b"), []byte("value-b"))
+
+	// 索引指向了另一个 key 的记录
+	db.index.Put([]byte("key-a"), db.index.Get([]byte("key-b")))
+	report, err := db.Verify(context.Background())
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(report.Corruptions) != 1 || report.Corruptions[0].Kind != CorruptionIndexMismatch {
+		t.Errorf("Verify() corruptions = %+v", report.Corruptions)
+	}
+}
+
+func TestDB_Verify_concurrentWrites(t *testing.T) {
+	options := defaultOptions()
+	options.MaxFileSize = 4 * 1024
+	db, err := Open(options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+	for i := 0; i < 2000; i++ {
+		_ = db.Put([]byte(fmt.Sprintf("key-%d", i)), []byte(fmt.Sprintf("value-%d", i)))
+	}
+
+	var wg sync.WaitGroup
+	var stop = make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			_ = db.Put([]byte(fmt.Sprintf("key-%d", i%2000)), []byte(fmt.Sprintf("new-%d", i)))
+			if i%7 == 0 {
+				_ = db.Delete([]byte(fmt.Sprintf("key-%d", (i+1)%2000)))
+			}
+		}
+	}()
+	report, err := db.Verify(context.Background())
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("Verify() corruptions = %+v", report.Corruptions)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = db.Verify(ctx); err != context.Canceled {
+		t.Errorf("Verify() with cancelled context error = %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"context"
+)
+
+type WatchEventType = byte
+
+const (
+	WatchEventPut    WatchEventType = iota + 1 // 写入数据
+	WatchEventDelete                           // 删除数据
+)
+
+// WatchEvent 数据变更事件
+type WatchEvent struct {
+	Type      WatchEventType
+	Key       []byte
+	Value     []byte // 删除事件的 value 为空
+	SeqId     uint64 // 写入的序列号，可用于判断事件的先后顺序
+	Namespace []byte // 数据所属的命名空间，默认命名空间为空
+}
+
+type WatchDropPolicy = byte
+
+const (
+	WatchDropNewest WatchDropPolicy = iota // 订阅者缓冲区已满时丢弃新的事件
+	WatchEvict                             // 订阅者缓冲区已满时关闭订阅
+)
+
+// WatchOption 订阅配置项
+type WatchOption struct {
+	BufferSize int             // 订阅通道的缓冲区大小，单位为批次
+	DropPolicy WatchDropPolicy // 缓冲区已满时的处理策略
+}
+
+var DefaultWatchOptions = WatchOption{
+	BufferSize: 1024,
+	DropPolicy: WatchDropNewest,
+}
+
+// watcher 订阅者
+type watcher struct {
+	namespace []byte
+	prefix    []byte
+	option    WatchOption
+	ch        chan []WatchEvent
+}
+
+// Watch 订阅前缀为 prefix 的 key 的变更，ctx 结束时关闭返回的通道
+// 每次从通道中读取到的是同一次提交产生的所有事件，写入方不会因为订阅者消费缓慢而阻塞
+func (db *DB) Watch(ctx context.Context, prefix []byte) <-chan []WatchEvent {
+	return db.WatchWithOption(ctx, prefix, DefaultWatchOptions)
+}
+
+// WatchWithOption 使用指定的配置订阅前缀为 prefix 的 key 的变更
+func (db *DB) WatchWithOption(ctx context.Context, prefix []byte, option WatchOption) <-chan []WatchEvent {
+	return db.watch(ctx, nil, prefix, option)
+}
+
+// watch 订阅命名空间 ns 中前缀为 prefix 的 key 的变更
+func (db *DB) watch(ctx context.Context, ns *Namespace, prefix []byte, option WatchOption) <-chan []WatchEvent {
+	if option.BufferSize <= 0 {
+		option.BufferSize = DefaultWatchOptions.BufferSize
+	}
+	w := &watcher{
+		namespace: namespaceName(ns),
+		prefix:    prefix,
+		option:    option,
+		ch:        make(chan []WatchEvent, option.BufferSize),
+	}
+
+	db.mu.Lock()
+	db.watchers[w] = struct{}{}
+	db.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-db.closeCh:
+		}
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		db.removeWatcher(w)
+	}()
+	return w.ch
+}
+
+// notifyWatchers 将一次提交产生的事件投递给订阅者，需要持有 db.mu
+func (db *DB) notifyWatchers(events []WatchEvent) {
+	for w := range db.watchers {
+		var matched []WatchEvent
+		for _, event := range events {
+			if bytes.Equal(event.Namespace, w.namespace) && bytes.HasPrefix(event.Key, w.prefix) {
+				matched = append(matched, event)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		select {
+		case w.ch <- matched:
+		default:
+			// 缓冲区已满，不能阻塞写入方，丢弃本次事件或关闭订阅
+			if w.option.DropPolicy == WatchEvict {
+				db.removeWatcher(w)
+			}
+		}
+	}
+}
+
+// removeWatcher 移除订阅者并关闭通道，需要持有 db.mu
+func (db *DB) removeWatcher(w *watcher) {
+	if _, ok := db.watchers[w]; !ok {
+		return
+	}
+	delete(db.watchers, w)
+	close(w.ch)
+}
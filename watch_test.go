@@ -0,0 +1,128 @@
+package bitcask_go
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func receiveWatchEvents(ch <-chan []WatchEvent, n int) [][]WatchEvent {
+	var batches [][]WatchEvent
+	var timeout = time.After(time.Second)
+	for len(batches) < n {
+		select {
+		case events, ok := <-ch:
+			if !ok {
+				return batches
+			}
+			batches = append(batches, events)
+		case <-timeout:
+			return batches
+		}
+	}
+	return batches
+}
+
+func TestDB_Watch(t *testing.T) {
+	db, err := Open(defaultOptions())
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+		return
+	}
+	defer destroyDB(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := db.Watch(ctx, []byte("user:"))
+
+	_ = db.Put([]byte("user:1"), []byte("a"))
+	_ = db.Put([]byte("order:1"), []byte("b"))
+	_ = db.Delete([]byte("user:1"))
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put([]byte("user:2"), []byte("c"))
+	_ = wb.Put([]byte("order:2"), []byte("d"))
+	_ = wb.Put([]byte("user:3"), []byte("e"))
+	if err = wb.Commit(); err != nil {
+		t.Errorf("Commit() error = %v", err)
+	}
+
+	batches := receiveWatchEvents(ch, 3)
+	if len(batches) != 3 {
+		t.Errorf("Watch() batches = %v, want 3", len(batches))
+		return
+	}
+	if got := batches[0]; len(got) != 1 || got[0].Type != WatchEventPut || string(got[0].Key) != "user:1" || string(got[0].Value) != "a" {
+		t.Errorf("Watch() put event = %+v", got)
+	}
+	if got := batches[1]; len(got) != 1 || got[0].Type != WatchEventDelete || string(got[0].Key) != "user:1" {
+		t.Errorf("Watch() delete event = %+v", got)
+	}
+	if batches[1][0].SeqId <= batches[0][0].SeqId {
+		t.Errorf("Watch() seqId not increasing, %v <= %v", batches[1][0].SeqId, batches[0][0].SeqId)
+	}
+	// 同一批次的事件一起投递，且只包含匹配前缀的 key
+	var keys = make(map[string]string)
+	for _, event := range batches[2] {
+		keys[string(event.Key)] = string(event.Value)
+		if event.SeqId != batches[2][0].SeqId {
+			t.Errorf("Watch() batch events have different seqId")
+		}
+	}
+	if want := map[string]string{"user:2": "c", "user:3": "e"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Watch() batch = %v, want %v", keys, want)
+	}
+
+	// 取消订阅后通道关闭
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Errorf("Watch() channel not closed after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Watch() channel not closed after cancel")
+	}
+}
+
+func TestDB_WatchWithOption(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     WatchDropPolicy
+		wantClosed bool
+	}{
+		{name: "drop newest", policy: WatchDropNewest, wantClosed: false},
+		{name: "evict", policy: WatchEvict, wantClosed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Open(defaultOptions())
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+				return
+			}
+			defer destroyDB(db)
+
+			ch := db.WatchWithOption(context.Background(), nil, WatchOption{BufferSize: 1, DropPolicy: tt.policy})
+			// 订阅者不消费时，写入不会阻塞
+			for i := 0; i < 10; i++ {
+				if err = db.Put([]byte("key"), []byte("value")); err != nil {
+					t.Errorf("Put() error = %v", err)
+				}
+			}
+
+			if _, ok := <-ch; !ok {
+				t.Errorf("Watch() first batch should be delivered")
+			}
+			var closed bool
+			select {
+			case _, ok := <-ch:
+				closed = !ok
+			case <-time.After(100 * time.Millisecond):
+			}
+			if closed != tt.wantClosed {
+				t.Errorf("Watch() closed = %v, want %v", closed, tt.wantClosed)
+			}
+		})
+	}
+}